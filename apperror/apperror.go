@@ -0,0 +1,71 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is an error meant for the API client. Handlers return it and the
+// central HTTP error handler renders it; Code is stable and safe for clients
+// to match on, unlike Message.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	// Err is the underlying cause. It is logged but never sent to the client.
+	Err error
+}
+
+// FieldError describes a problem with one input field or query parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Internal returns a 500 error; the message should describe what failed
+// without exposing internals.
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Validation returns a 400 error for a single invalid field.
+func Validation(field, message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message).WithDetails(FieldError{Field: field, Message: message})
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns a copy of e with field-level details attached.
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	copied := *e
+	copied.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &copied
+}
+
+// WithCause returns a copy of e wrapping err for logging.
+func (e *Error) WithCause(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// As reports whether err is or wraps an *Error and returns it.
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	ok := errors.As(err, &appErr)
+	return appErr, ok
+}
@@ -0,0 +1,94 @@
+package apperror
+
+// Error codes returned in the "code" field of error responses. They are part
+// of the API contract: add new codes freely, but never rename or reuse one.
+const (
+	// Generic
+	CodeInternal              = "INTERNAL_ERROR"
+	CodeInvalidRequestBody    = "INVALID_REQUEST_BODY"
+	CodeValidationFailed      = "VALIDATION_FAILED"
+	CodeInvalidQueryParameter = "INVALID_QUERY_PARAMETER"
+	CodeRouteNotFound         = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed      = "METHOD_NOT_ALLOWED"
+	CodeRequestTooLarge       = "REQUEST_TOO_LARGE"
+	CodeUnsupportedMediaType  = "UNSUPPORTED_MEDIA_TYPE"
+	CodeBadRequest            = "BAD_REQUEST"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeForbidden             = "FORBIDDEN"
+	CodeNotFound              = "NOT_FOUND"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeServiceUnavailable    = "SERVICE_UNAVAILABLE"
+
+	// Authentication
+	CodeMissingAuthorization       = "MISSING_AUTHORIZATION"
+	CodeInvalidAuthorizationHeader = "INVALID_AUTHORIZATION_HEADER"
+	CodeInvalidToken               = "INVALID_TOKEN"
+	CodeTokenRevoked               = "TOKEN_REVOKED"
+	CodeSessionRevoked             = "SESSION_REVOKED"
+	CodeInvalidAPIKey              = "INVALID_API_KEY"
+	CodeInvalidCredentials         = "INVALID_CREDENTIALS"
+	CodeAccountDeactivated         = "ACCOUNT_DEACTIVATED"
+	CodePasswordResetRequired      = "PASSWORD_RESET_REQUIRED"
+	CodeTooManyLoginAttempts       = "TOO_MANY_LOGIN_ATTEMPTS"
+	CodeInvalidResetToken          = "INVALID_RESET_TOKEN"
+	CodeInvalidUnlockToken         = "INVALID_UNLOCK_TOKEN"
+
+	// Two-factor authentication
+	CodeInvalidChallenge            = "INVALID_CHALLENGE"
+	CodeChallengeAttemptsExceeded   = "CHALLENGE_ATTEMPTS_EXCEEDED"
+	CodeInvalidTwoFactorCode        = "INVALID_TWO_FACTOR_CODE"
+	CodeTwoFactorRequired           = "TWO_FACTOR_REQUIRED"
+	CodeTwoFactorNotEnabled         = "TWO_FACTOR_NOT_ENABLED"
+	CodeTwoFactorAlreadyEnabled     = "TWO_FACTOR_ALREADY_ENABLED"
+	CodeTwoFactorEnrollmentNotFound = "TWO_FACTOR_ENROLLMENT_NOT_FOUND"
+
+	// OpenID Connect
+	CodeOIDCNotConfigured           = "OIDC_NOT_CONFIGURED"
+	CodeOIDCLoginFailed             = "OIDC_LOGIN_FAILED"
+	CodeInvalidLoginState           = "INVALID_LOGIN_STATE"
+	CodeEmailNotVerified            = "EMAIL_NOT_VERIFIED"
+	CodeIdentityProviderUnavailable = "IDENTITY_PROVIDER_UNAVAILABLE"
+	CodeAccountLinkRequired         = "ACCOUNT_LINK_REQUIRED"
+	CodeIdentityAlreadyLinked       = "IDENTITY_ALREADY_LINKED"
+
+	// Authorization
+	CodeInsufficientScope       = "INSUFFICIENT_SCOPE"
+	CodeAPIKeyNotAllowed        = "API_KEY_NOT_ALLOWED"
+	CodeNotPropertyOwner        = "NOT_PROPERTY_OWNER"
+	CodeImpersonationNotAllowed = "IMPERSONATION_NOT_ALLOWED"
+	CodeSelfActionNotAllowed    = "SELF_ACTION_NOT_ALLOWED"
+
+	// Users
+	CodeUserNotFound         = "USER_NOT_FOUND"
+	CodeInvalidUserID        = "INVALID_USER_ID"
+	CodeEmailTaken           = "EMAIL_TAKEN"
+	CodeInvalidRole          = "INVALID_ROLE"
+	CodeUserDeactivated      = "USER_DEACTIVATED"
+	CodeInvalidHandle        = "INVALID_HANDLE"
+	CodeHandleTaken          = "HANDLE_TAKEN"
+	CodeInvalidUserLookup    = "INVALID_USER_LOOKUP"
+	CodeTooManyLookups       = "TOO_MANY_LOOKUPS"
+	CodeDeletionNotScheduled = "DELETION_NOT_SCHEDULED"
+	CodeDeletionInProgress   = "DELETION_IN_PROGRESS"
+
+	// Sessions and API keys
+	CodeSessionNotFound    = "SESSION_NOT_FOUND"
+	CodeInvalidSessionID   = "INVALID_SESSION_ID"
+	CodeAPIKeyNotFound     = "API_KEY_NOT_FOUND"
+	CodeInvalidAPIKeyID    = "INVALID_API_KEY_ID"
+	CodeInvalidScope       = "INVALID_SCOPE"
+	CodeAPIKeyLimitReached = "API_KEY_LIMIT_REACHED"
+
+	// Properties
+	CodePropertyNotFound  = "PROPERTY_NOT_FOUND"
+	CodeInvalidPropertyID = "INVALID_PROPERTY_ID"
+	CodeInvalidExternalID = "INVALID_EXTERNAL_ID"
+	CodePropertyExists    = "PROPERTY_EXISTS"
+	CodeInvalidComparison = "INVALID_COMPARISON"
+	CodeBatchTooLarge     = "BATCH_TOO_LARGE"
+	CodeAlreadyFavorited  = "ALREADY_FAVORITED"
+
+	// Recommendations
+	CodeRecipientNotFound = "RECIPIENT_NOT_FOUND"
+	CodeInvalidEmail      = "INVALID_EMAIL"
+)
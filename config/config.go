@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+)
+
+// App is the configuration loaded at startup.
+var App *Config
+
+// Config holds every setting of the service. A field takes its value from,
+// in order of precedence: the file named by its env variable suffixed with
+// _FILE (for secrets mounted as files), the env variable itself, its key path
+// in the optional YAML/TOML config file, and finally its default tag.
+type Config struct {
+	Server      ServerConfig      `key:"server"`
+	Mongo       MongoConfig       `key:"mongodb"`
+	Redis       RedisConfig       `key:"redis"`
+	JWT         JWTConfig         `key:"jwt"`
+	OIDC        OIDCConfig        `key:"oidc"`
+	SMTP        SMTPConfig        `key:"smtp"`
+	Links       LinksConfig       `key:"links"`
+	Login       LoginConfig       `key:"login"`
+	Users       UsersConfig       `key:"users"`
+	Privacy     PrivacyConfig     `key:"privacy"`
+	Properties  PropertiesConfig  `key:"properties"`
+	Recommender RecommenderConfig `key:"recommender"`
+	Tracing     TracingConfig     `key:"tracing"`
+	Log         LogConfig         `key:"log"`
+	Errors      ErrorsConfig      `key:"errors"`
+	RateLimit   RateLimitConfig   `key:"rate_limit"`
+}
+
+type ServerConfig struct {
+	Port                   int `key:"port" env:"PORT" default:"8080" min:"1"`
+	ReadTimeoutSeconds     int `key:"read_timeout_seconds" env:"SERVER_READ_TIMEOUT_SECONDS" default:"15" min:"1"`
+	WriteTimeoutSeconds    int `key:"write_timeout_seconds" env:"SERVER_WRITE_TIMEOUT_SECONDS" default:"30" min:"1"`
+	IdleTimeoutSeconds     int `key:"idle_timeout_seconds" env:"SERVER_IDLE_TIMEOUT_SECONDS" default:"60" min:"1"`
+	ShutdownTimeoutSeconds int `key:"shutdown_timeout_seconds" env:"SERVER_SHUTDOWN_TIMEOUT_SECONDS" default:"30" min:"1"`
+	ReadinessTimeoutMillis int `key:"readiness_timeout_ms" env:"READINESS_TIMEOUT_MS" default:"2000" min:"1"`
+	// TrustedProxies lists the CIDRs of reverse proxies whose X-Forwarded-For
+	// header is believed. Empty means the client IP is the peer address.
+	TrustedProxies []string `key:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+type MongoConfig struct {
+	URI      string `key:"uri" env:"MONGODB_URI" secret:"true"`
+	Database string `key:"database" env:"MONGODB_DATABASE"`
+	// OperationTimeoutMillis bounds each MongoDB operation that is not
+	// already under a shorter deadline.
+	OperationTimeoutMillis int               `key:"operation_timeout_ms" env:"MONGODB_OPERATION_TIMEOUT_MS" default:"5000" min:"1"`
+	Collections            CollectionsConfig `key:"collections"`
+}
+
+type CollectionsConfig struct {
+	User            string `key:"user" env:"MONGODB_COLLECTION_USER" default:"user"`
+	Properties      string `key:"properties" env:"MONGODB_COLLECTION_PROPERTIES" default:"properties"`
+	Favorites       string `key:"favorites" env:"MONGODB_COLLECTION_FAVORITES" default:"favorites"`
+	Recommendations string `key:"recommendations" env:"MONGODB_COLLECTION_RECOMMENDATIONS" default:"recommendations"`
+	Views           string `key:"views" env:"MONGODB_COLLECTION_VIEWS" default:"views"`
+	Audit           string `key:"audit" env:"MONGODB_COLLECTION_AUDIT" default:"audit_logs"`
+	Settings        string `key:"settings" env:"MONGODB_COLLECTION_SETTINGS" default:"settings"`
+	Sessions        string `key:"sessions" env:"MONGODB_COLLECTION_SESSIONS" default:"sessions"`
+	APIKeys         string `key:"api_keys" env:"MONGODB_COLLECTION_API_KEYS" default:"api_keys"`
+}
+
+type RedisConfig struct {
+	Addr     string `key:"addr" env:"REDIS_ADDR" default:"localhost:6379"`
+	Password string `key:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `key:"db" env:"REDIS_DB" default:"0" min:"0"`
+	// TimeoutMillis bounds every Redis command; cache reads and writes use
+	// the tighter CacheTimeoutMillis and fall back to MongoDB when it expires.
+	TimeoutMillis      int `key:"timeout_ms" env:"REDIS_TIMEOUT_MS" default:"1000" min:"1"`
+	CacheTimeoutMillis int `key:"cache_timeout_ms" env:"CACHE_TIMEOUT_MS" default:"100" min:"1"`
+}
+
+type JWTConfig struct {
+	Secret                  string   `key:"secret" env:"JWT_SECRET" secret:"true"`
+	ExpiryHours             int      `key:"expiry_hours" env:"JWT_EXPIRY_HOURS" default:"24" min:"1"`
+	KeysDir                 string   `key:"keys_dir" env:"JWT_KEYS_DIR"`
+	SigningKID              string   `key:"signing_kid" env:"JWT_SIGNING_KID"`
+	AllowedAlgs             []string `key:"allowed_algs" env:"JWT_ALLOWED_ALGS" default:"RS256,EdDSA"`
+	Issuer                  string   `key:"issuer" env:"JWT_ISSUER" default:"PropertyListingSys"`
+	Audience                string   `key:"audience" env:"JWT_AUDIENCE" default:"property-listing-api"`
+	ImpersonationTTLMinutes int      `key:"impersonation_ttl_minutes" env:"IMPERSONATION_TTL_MINUTES" default:"15" min:"1"`
+}
+
+type OIDCConfig struct {
+	Issuer       string   `key:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string   `key:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string   `key:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL  string   `key:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes       []string `key:"scopes" env:"OIDC_SCOPES" default:"openid,email,profile"`
+}
+
+type SMTPConfig struct {
+	Host     string `key:"host" env:"SMTP_HOST"`
+	Port     int    `key:"port" env:"SMTP_PORT" default:"587" min:"1"`
+	Username string `key:"username" env:"SMTP_USERNAME"`
+	Password string `key:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `key:"from" env:"SMTP_FROM" default:"no-reply@propertylistingsys.local"`
+	// TimeoutSeconds bounds the whole SMTP exchange, including the dial.
+	TimeoutSeconds int `key:"timeout_seconds" env:"SMTP_TIMEOUT_SECONDS" default:"10" min:"1"`
+	// LogBodies makes the log mailer used without SMTP_HOST log message
+	// bodies at debug level. Bodies hold live reset and unlock tokens, so
+	// this is for local development only.
+	LogBodies bool `key:"log_bodies" env:"MAIL_LOG_BODIES" default:"false"`
+}
+
+// LinksConfig holds the pages that emailed links open. They must be frontend
+// pages: the API endpoints behind them only accept POST, so a link straight
+// to the API would fail when clicked.
+type LinksConfig struct {
+	SignupURL        string `key:"signup_url" env:"SIGNUP_URL" default:"http://localhost:3000/signup"`
+	PasswordResetURL string `key:"password_reset_url" env:"PASSWORD_RESET_URL" default:"http://localhost:3000/reset-password"`
+	AccountUnlockURL string `key:"account_unlock_url" env:"ACCOUNT_UNLOCK_URL" default:"http://localhost:3000/unlock"`
+}
+
+type LoginConfig struct {
+	BackoffThreshold   int    `key:"backoff_threshold" env:"LOGIN_BACKOFF_THRESHOLD" default:"3" min:"1"`
+	BackoffBaseSeconds int    `key:"backoff_base_seconds" env:"LOGIN_BACKOFF_BASE_SECONDS" default:"1" min:"1"`
+	MaxAttempts        int    `key:"max_attempts" env:"LOGIN_MAX_ATTEMPTS" default:"10" min:"1"`
+	IPMaxAttempts      int    `key:"ip_max_attempts" env:"LOGIN_IP_MAX_ATTEMPTS" default:"50" min:"1"`
+	LockoutMinutes     int    `key:"lockout_minutes" env:"LOGIN_LOCKOUT_MINUTES" default:"15" min:"1"`
+	TOTPIssuer         string `key:"totp_issuer" env:"TOTP_ISSUER" default:"PropertyListingSys"`
+}
+
+type UsersConfig struct {
+	LookupLimit         int `key:"lookup_limit" env:"USER_LOOKUP_LIMIT" default:"20" min:"1"`
+	LookupWindowMinutes int `key:"lookup_window_minutes" env:"USER_LOOKUP_WINDOW_MINUTES" default:"60" min:"1"`
+}
+
+type PrivacyConfig struct {
+	DeletionGraceDays    int `key:"deletion_grace_days" env:"ACCOUNT_DELETION_GRACE_DAYS" default:"30" min:"0"`
+	PurgeIntervalMinutes int `key:"purge_interval_minutes" env:"ACCOUNT_PURGE_INTERVAL_MINUTES" default:"60" min:"1"`
+}
+
+type PropertiesConfig struct {
+	BatchMaxItems        int `key:"batch_max_items" env:"BATCH_MAX_ITEMS" default:"100" min:"1"`
+	CompareMaxProperties int `key:"compare_max_properties" env:"COMPARE_MAX_PROPERTIES" default:"5" min:"2"`
+}
+
+type RecommenderConfig struct {
+	Limit           int                    `key:"limit" env:"RECOMMENDER_LIMIT" default:"20" min:"1"`
+	IntervalMinutes int                    `key:"interval_minutes" env:"RECOMMENDER_INTERVAL_MINUTES" default:"30" min:"1"`
+	Weights         SimilarityWeightConfig `key:"similarity_weights"`
+}
+
+type SimilarityWeightConfig struct {
+	Price     float64 `key:"price" env:"SIMILAR_WEIGHT_PRICE" default:"3" min:"0"`
+	Area      float64 `key:"area" env:"SIMILAR_WEIGHT_AREA" default:"2" min:"0"`
+	Bedrooms  float64 `key:"bedrooms" env:"SIMILAR_WEIGHT_BEDROOMS" default:"2" min:"0"`
+	Bathrooms float64 `key:"bathrooms" env:"SIMILAR_WEIGHT_BATHROOMS" default:"1" min:"0"`
+	Type      float64 `key:"type" env:"SIMILAR_WEIGHT_TYPE" default:"2" min:"0"`
+	City      float64 `key:"city" env:"SIMILAR_WEIGHT_CITY" default:"3" min:"0"`
+	Amenities float64 `key:"amenities" env:"SIMILAR_WEIGHT_AMENITIES" default:"1" min:"0"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `key:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	File        string  `key:"file" env:"TRACING_FILE" default:"traces.jsonl"`
+	ServiceName string  `key:"service_name" env:"OTEL_SERVICE_NAME" default:"property-listing-sys"`
+	SampleRatio float64 `key:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" min:"0"`
+}
+
+type LogConfig struct {
+	Level  string `key:"level" env:"LOG_LEVEL" default:"info"`
+	Format string `key:"format" env:"LOG_FORMAT" default:"json"`
+}
+
+type ErrorsConfig struct {
+	Format string `key:"format" env:"ERROR_FORMAT" default:"json"`
+}
+
+// RateLimitConfig sets the request quota of each route group; see
+// middleware.RateLimit.
+type RateLimitConfig struct {
+	Enabled             bool `key:"enabled" env:"RATE_LIMIT_ENABLED" default:"true"`
+	AuthRequests        int  `key:"auth_requests" env:"RATE_LIMIT_AUTH_REQUESTS" default:"20" min:"1"`
+	AuthWindowSeconds   int  `key:"auth_window_seconds" env:"RATE_LIMIT_AUTH_WINDOW_SECONDS" default:"60" min:"1"`
+	PublicRequests      int  `key:"public_requests" env:"RATE_LIMIT_PUBLIC_REQUESTS" default:"120" min:"1"`
+	PublicWindowSeconds int  `key:"public_window_seconds" env:"RATE_LIMIT_PUBLIC_WINDOW_SECONDS" default:"60" min:"1"`
+	APIRequests         int  `key:"api_requests" env:"RATE_LIMIT_API_REQUESTS" default:"300" min:"1"`
+	APIWindowSeconds    int  `key:"api_window_seconds" env:"RATE_LIMIT_API_WINDOW_SECONDS" default:"60" min:"1"`
+}
+
+var validJWTAlgs = map[string]bool{"RS256": true, "EdDSA": true, "HS256": true}
+
+// Validate checks settings that depend on each other; per-field type and
+// range errors are reported while loading.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port > 65535 {
+		add("PORT: must be at most 65535")
+	}
+	for _, cidr := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			add("TRUSTED_PROXIES: invalid CIDR %q", cidr)
+		}
+	}
+	if c.Mongo.URI == "" {
+		add("MONGODB_URI: is required")
+	}
+	if c.Mongo.Database == "" {
+		add("MONGODB_DATABASE: is required")
+	}
+
+	if c.JWT.KeysDir == "" && c.JWT.Secret == "" {
+		add("JWT_KEYS_DIR or JWT_SECRET: one is required")
+	}
+	if c.JWT.KeysDir != "" && c.JWT.SigningKID == "" {
+		add("JWT_SIGNING_KID: is required when JWT_KEYS_DIR is set")
+	}
+	for _, alg := range c.JWT.AllowedAlgs {
+		if !validJWTAlgs[alg] {
+			add("JWT_ALLOWED_ALGS: unsupported algorithm %q", alg)
+		}
+	}
+
+	if c.OIDC.Issuer != "" {
+		if c.OIDC.ClientID == "" {
+			add("OIDC_CLIENT_ID: is required when OIDC_ISSUER is set")
+		}
+		if c.OIDC.RedirectURL == "" {
+			add("OIDC_REDIRECT_URL: is required when OIDC_ISSUER is set")
+		}
+	}
+
+	if c.SMTP.Host != "" {
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			add("SMTP_FROM: invalid address %q", c.SMTP.From)
+		}
+	}
+	if strings.ContainsAny(c.SMTP.From, "\r\n") {
+		add("SMTP_FROM: must not contain line breaks")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout", "file":
+	default:
+		add("TRACING_EXPORTER: must be one of none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio > 1 {
+		add("TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL: must be one of debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		add("LOG_FORMAT: must be json or text, got %q", c.Log.Format)
+	}
+
+	if c.Errors.Format != "json" && c.Errors.Format != "problem" {
+		add("ERROR_FORMAT: must be json or problem, got %q", c.Errors.Format)
+	}
+
+	return errors.Join(errs...)
+}
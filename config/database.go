@@ -1,43 +1,82 @@
 package config
 
 import (
+	"PropertyListingSys/metrics"
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
-var DB *mongo.Database
+var (
+	Client *mongo.Client
+	DB     *mongo.Database
+)
 
 func ConnectDB() {
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		log.Fatal("MONGODB_URI environment variable is not set")
-	}
-
-	dbName := os.Getenv("MONGODB_DATABASE")
-	if dbName == "" {
-		log.Fatal("MONGODB_DATABASE environment variable is not set")
-	}
+	mongoURI := App.Mongo.URI
+	dbName := App.Mongo.Database
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(mongoURI).
+		SetTimeout(time.Duration(App.Mongo.OperationTimeoutMillis)*time.Millisecond).
+		SetMonitor(combineMonitors(
+			metrics.NewMongoMonitor(),
+			otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(true)),
+		)))
 	if err != nil {
-		log.Fatal("Failed to connect to MongoDB:", err)
+		slog.Error("Failed to connect to MongoDB", "error", err)
+		os.Exit(1)
 	}
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Fatal("Failed to ping MongoDB:", err)
+		slog.Error("Failed to ping MongoDB", "error", err)
+		os.Exit(1)
 	}
 
+	Client = client
 	DB = client.Database(dbName)
-	log.Println("Connected to MongoDB successfully!")
+	slog.Info("Connected to MongoDB successfully!")
+}
+
+// DisconnectDB closes the MongoDB client, waiting for in-use connections to
+// be returned to the pool until ctx expires.
+func DisconnectDB(ctx context.Context) error {
+	if Client == nil {
+		return nil
+	}
+	return Client.Disconnect(ctx)
+}
+
+// combineMonitors fans each command event out to several monitors, since the
+// driver accepts only one.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, evt)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, evt)
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, evt)
+			}
+		},
+	}
 }
 
 func GetCollection(collectionName string) *mongo.Collection {
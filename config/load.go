@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Load builds the configuration from defaults, the optional YAML or TOML
+// file at path (or CONFIG_FILE), .env and the process environment, in
+// increasing order of precedence. Every invalid or missing value is
+// reported, not just the first.
+func Load(path string) (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No .env file found, using system environment variables")
+	}
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	file := map[string]interface{}{}
+	if path != "" {
+		var err error
+		if file, err = readConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &Config{}
+	var errs []error
+	loadStruct(reflect.ValueOf(cfg).Elem(), "", file, &errs)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	file := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+func loadStruct(v reflect.Value, prefix string, file map[string]interface{}, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("key")
+		path := prefix + key
+		fileValue, inFile := file[key]
+		delete(file, key)
+
+		if field.Type.Kind() == reflect.Struct {
+			section := map[string]interface{}{}
+			if inFile {
+				m, ok := fileValue.(map[string]interface{})
+				if !ok {
+					*errs = append(*errs, fmt.Errorf("%s: expected a section in config file", path))
+					continue
+				}
+				section = m
+			}
+			loadStruct(v.Field(i), path+".", section, errs)
+			continue
+		}
+
+		if err := loadField(v.Field(i), field, path, fileValue, inFile); err != nil {
+			*errs = append(*errs, err)
+		}
+	}
+
+	for key := range file {
+		*errs = append(*errs, fmt.Errorf("%s%s: unknown key in config file", prefix, key))
+	}
+}
+
+func loadField(v reflect.Value, field reflect.StructField, path string, fileValue interface{}, inFile bool) error {
+	env := field.Tag.Get("env")
+	name := env
+	raw := field.Tag.Get("default")
+
+	if inFile {
+		raw = fileString(fileValue)
+		name = path
+	}
+	if secretFile := os.Getenv(env + "_FILE"); secretFile != "" {
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", env, err)
+		}
+		raw = strings.TrimRight(string(data), "\r\n")
+		name = env + "_FILE"
+	} else if value := os.Getenv(env); value != "" {
+		raw = value
+		name = env
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", name, raw)
+		}
+		if minTag, ok := field.Tag.Lookup("min"); ok {
+			if m, _ := strconv.Atoi(minTag); n < m {
+				return fmt.Errorf("%s: must be at least %d, got %d", name, m, n)
+			}
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", name, raw)
+		}
+		if minTag, ok := field.Tag.Lookup("min"); ok {
+			if m, _ := strconv.ParseFloat(minTag, 64); f < m {
+				return fmt.Errorf("%s: must be at least %s, got %s", name, minTag, raw)
+			}
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", name, raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s: unsupported config type %s", name, v.Kind())
+	}
+	return nil
+}
+
+// fileString renders a decoded YAML/TOML value in the same textual form as
+// an environment variable so both go through one parser.
+func fileString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Print writes the effective configuration as YAML, usable as a config file,
+// with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	var root yaml.Node
+	root.Kind = yaml.MappingNode
+	printStruct(&root, reflect.ValueOf(c).Elem())
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func printStruct(node *yaml.Node, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("key")}
+		if env := field.Tag.Get("env"); env != "" {
+			keyNode.LineComment = env
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			section := &yaml.Node{Kind: yaml.MappingNode}
+			printStruct(section, v.Field(i))
+			node.Content = append(node.Content, keyNode, section)
+			continue
+		}
+
+		var valueNode yaml.Node
+		value := v.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" && v.Field(i).String() != "" {
+			value = redacted
+		}
+		valueNode.Encode(value)
+		node.Content = append(node.Content, keyNode, &valueNode)
+	}
+}
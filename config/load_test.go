@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requiredEnv is the smallest environment that passes Validate.
+var requiredEnv = map[string]string{
+	"MONGODB_URI":      "mongodb://localhost:27017",
+	"MONGODB_DATABASE": "test",
+	"JWT_SECRET":       "test-secret",
+}
+
+// setEnv applies requiredEnv and then env, and clears the variables the
+// tests read so the caller's environment cannot leak in.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"CONFIG_FILE", "PORT", "PORT_FILE", "JWT_SECRET_FILE", "LOG_LEVEL", "RATE_LIMIT_ENABLED", "JWT_ALLOWED_ALGS", "TRACING_SAMPLE_RATIO"} {
+		t.Setenv(name, "")
+	}
+	for name, value := range requiredEnv {
+		t.Setenv(name, value)
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		secret   string
+		wantPort int
+	}{
+		{name: "default", wantPort: 8080},
+		{name: "file over default", file: "server:\n  port: 9000\n", wantPort: 9000},
+		{name: "env over file", file: "server:\n  port: 9000\n", env: map[string]string{"PORT": "9100"}, wantPort: 9100},
+		{name: "_FILE over env", file: "server:\n  port: 9000\n", env: map[string]string{"PORT": "9100"}, secret: "9200\n", wantPort: 9200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			if tt.secret != "" {
+				t.Setenv("PORT_FILE", writeFile(t, "port", tt.secret))
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yaml", tt.file)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != tt.wantPort {
+				t.Errorf("port = %d, want %d", cfg.Server.Port, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestLoadCoercesTypes(t *testing.T) {
+	setEnv(t, map[string]string{
+		"RATE_LIMIT_ENABLED":   "false",
+		"JWT_ALLOWED_ALGS":     "RS256, HS256",
+		"TRACING_SAMPLE_RATIO": "0.25",
+	})
+	path := writeFile(t, "config.toml", "[log]\nlevel = \"debug\"\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RateLimit.Enabled {
+		t.Error("rate limiting enabled, want disabled")
+	}
+	if got := strings.Join(cfg.JWT.AllowedAlgs, ","); got != "RS256,HS256" {
+		t.Errorf("allowed algs = %q, want RS256,HS256", got)
+	}
+	if cfg.Tracing.SampleRatio != 0.25 {
+		t.Errorf("sample ratio = %v, want 0.25", cfg.Tracing.SampleRatio)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("log level = %q, want debug from the TOML file", cfg.Log.Level)
+	}
+}
+
+func TestLoadTrimsSecretFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "newline", content: "s3cret\n", want: "s3cret"},
+		{name: "crlf", content: "s3cret\r\n", want: "s3cret"},
+		{name: "inner and leading spaces kept", content: " s3 cret\n", want: " s3 cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, nil)
+			t.Setenv("JWT_SECRET_FILE", writeFile(t, "secret", tt.content))
+
+			cfg, err := Load("")
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.JWT.Secret != tt.want {
+				t.Errorf("secret = %q, want %q", cfg.JWT.Secret, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsBadValues(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want []string
+	}{
+		{name: "integer", env: map[string]string{"PORT": "abc"}, want: []string{`PORT: invalid integer "abc"`}},
+		{name: "below minimum", env: map[string]string{"PORT": "0"}, want: []string{"PORT: must be at least 1, got 0"}},
+		{name: "boolean", env: map[string]string{"RATE_LIMIT_ENABLED": "maybe"}, want: []string{`RATE_LIMIT_ENABLED: invalid boolean "maybe"`}},
+		{name: "number", env: map[string]string{"TRACING_SAMPLE_RATIO": "half"}, want: []string{`TRACING_SAMPLE_RATIO: invalid number "half"`}},
+		{name: "file value named by path", file: "server:\n  port: abc\n", want: []string{`server.port: invalid integer "abc"`}},
+		{name: "scalar for a section", file: "server: 8080\n", want: []string{"server: expected a section in config file"}},
+		{name: "unknown key", file: "server:\n  prot: 8080\n", want: []string{"server.prot: unknown key in config file"}},
+		{name: "missing secret file", env: map[string]string{"JWT_SECRET_FILE": "/nonexistent/secret"}, want: []string{"JWT_SECRET_FILE:"}},
+		{
+			name: "every error reported",
+			env:  map[string]string{"PORT": "abc", "RATE_LIMIT_ENABLED": "maybe"},
+			want: []string{"PORT: invalid integer", "RATE_LIMIT_ENABLED: invalid boolean"},
+		},
+		{name: "validation", env: map[string]string{"LOG_LEVEL": "verbose"}, want: []string{"LOG_LEVEL: must be one of"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yaml", tt.file)
+			}
+
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("Load succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	setEnv(t, map[string]string{
+		"MONGODB_URI": "mongodb://admin:hunter2@db:27017",
+		"JWT_SECRET":  "jwt-signing-secret",
+	})
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	printed := out.String()
+	for _, secret := range []string{"hunter2", "jwt-signing-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("printed config contains secret %q", secret)
+		}
+	}
+	if !strings.Contains(printed, "uri: '"+redacted+"'") && !strings.Contains(printed, "uri: "+redacted) {
+		t.Errorf("MongoDB URI is not redacted:\n%s", printed)
+	}
+	if !strings.Contains(printed, "database: test") {
+		t.Errorf("non-secret values are missing:\n%s", printed)
+	}
+
+	// The output is itself a valid config file.
+	t.Setenv("MONGODB_URI", "mongodb://localhost:27017")
+	t.Setenv("JWT_SECRET", "test-secret")
+	if _, err := Load(writeFile(t, "printed.yaml", printed)); err != nil {
+		t.Errorf("printed config does not load: %v", err)
+	}
+}
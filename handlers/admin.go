@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AdminController struct {
+	userCollection  *mongo.Collection
+	auditCollection *mongo.Collection
+}
+
+func NewAdminController() *AdminController {
+	return &AdminController{
+		userCollection:  config.GetCollection(config.App.Mongo.Collections.User),
+		auditCollection: config.GetCollection(config.App.Mongo.Collections.Audit),
+	}
+}
+
+func (ac *AdminController) ListUsers(c echo.Context) error {
+	query := bson.M{}
+
+	if role := c.QueryParam("role"); role != "" {
+		query["role"] = role
+	}
+	if active := c.QueryParam("active"); active != "" {
+		isActive, err := strconv.ParseBool(active)
+		if err != nil {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidQueryParameter, "Invalid active filter").WithDetails(apperror.FieldError{Field: "active", Message: "must be true or false"})
+		}
+		query["is_active"] = isActive
+	}
+	createdAt := bson.M{}
+	if from := c.QueryParam("created_from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidQueryParameter, "Invalid created_from format").WithDetails(apperror.FieldError{Field: "created_from", Message: "must be a date in YYYY-MM-DD format"})
+		}
+		createdAt["$gte"] = date
+	}
+	if to := c.QueryParam("created_to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidQueryParameter, "Invalid created_to format").WithDetails(apperror.FieldError{Field: "created_to", Message: "must be a date in YYYY-MM-DD format"})
+		}
+		createdAt["$lt"] = date.AddDate(0, 0, 1)
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	if q := c.QueryParam("q"); q != "" {
+		pattern := regexp.QuoteMeta(q)
+		query["$or"] = bson.A{
+			bson.M{"email": bson.M{"$regex": pattern, "$options": "i"}},
+			bson.M{"name": bson.M{"$regex": pattern, "$options": "i"}},
+		}
+	}
+
+	page := 1
+	limit := 20
+	if p := c.QueryParam("page"); p != "" {
+		if num, err := strconv.Atoi(p); err == nil && num > 0 {
+			page = num
+		}
+	}
+	if l := c.QueryParam("limit"); l != "" {
+		if num, err := strconv.Atoi(l); err == nil && num > 0 {
+			limit = min(num, 100)
+		}
+	}
+
+	ctx := c.Request().Context()
+	total, err := ac.userCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return apperror.Internal("Failed to count users")
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"password": 0})
+	cursor, err := ac.userCollection.Find(ctx, query, opts)
+	if err != nil {
+		return apperror.Internal("Failed to fetch users")
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable user document", "error", err)
+			continue
+		}
+		users = append(users, user)
+	}
+
+	return c.JSON(http.StatusOK, models.UserListResponse{
+		Users: users,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	})
+}
+
+func (ac *AdminController) GetUser(c echo.Context) error {
+	user, err := ac.findUser(c)
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+	return c.JSON(http.StatusOK, user)
+}
+
+func (ac *AdminController) UpdateUserStatus(c echo.Context) error {
+	var req models.UpdateUserStatusRequest
+	if err := c.Bind(&req); err != nil || req.Active == nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	targetID, err := ac.targetID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := ac.updateUser(c, targetID, bson.M{"is_active": *req.Active})
+	if err != nil {
+		return err
+	}
+
+	action := "user.activate"
+	if !*req.Active {
+		action = "user.deactivate"
+		if err := utils.RevokeUserTokens(c.Request().Context(), user.ID); err != nil {
+			return apperror.Internal("Failed to revoke user tokens")
+		}
+	}
+	ac.audit(c, action, user.ID, nil)
+
+	return c.JSON(http.StatusOK, user)
+}
+
+func (ac *AdminController) ForcePasswordReset(c echo.Context) error {
+	targetID, err := ac.targetID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := ac.updateUser(c, targetID, bson.M{"password_reset_required": true})
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if err := utils.RevokeUserTokens(ctx, user.ID); err != nil {
+		return apperror.Internal("Failed to revoke user tokens")
+	}
+
+	if err := sendPasswordResetEmail(ctx, user); err != nil {
+		return apperror.Internal("Failed to send password reset email")
+	}
+
+	ac.audit(c, "user.force_password_reset", user.ID, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password reset required; reset link sent to " + user.Email,
+	})
+}
+
+func (ac *AdminController) UnlockUser(c echo.Context) error {
+	user, err := ac.findUser(c)
+	if err != nil {
+		return err
+	}
+
+	utils.ResetLoginFailures(c.Request().Context(), user.Email)
+	ac.audit(c, "user.unlock", user.ID, nil)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Account unlocked successfully"})
+}
+
+func (ac *AdminController) AssignRole(c echo.Context) error {
+	var req models.AssignRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if !utils.IsValidRole(req.Role) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRole, "Invalid role")
+	}
+	return ac.setRole(c, req.Role)
+}
+
+func (ac *AdminController) RevokeRole(c echo.Context) error {
+	return ac.setRole(c, utils.RoleUser)
+}
+
+func (ac *AdminController) setRole(c echo.Context, role string) error {
+	targetID, err := ac.targetID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := ac.updateUser(c, targetID, bson.M{"role": role})
+	if err != nil {
+		return err
+	}
+
+	if err := utils.RevokeUserTokens(c.Request().Context(), user.ID); err != nil {
+		return apperror.Internal("Failed to revoke user tokens")
+	}
+	ac.audit(c, "user.role_change", user.ID, map[string]interface{}{"role": role})
+
+	return c.JSON(http.StatusOK, user)
+}
+
+func (ac *AdminController) ListRoles(c echo.Context) error {
+	return c.JSON(http.StatusOK, utils.RolePermissions)
+}
+
+func (ac *AdminController) GetTwoFactorPolicy(c echo.Context) error {
+	settings, err := utils.GetSecuritySettings(c.Request().Context())
+	if err != nil {
+		return apperror.Internal("Failed to load security settings")
+	}
+	return c.JSON(http.StatusOK, models.TwoFactorPolicyRequest{RequiredRoles: settings.TwoFactorRequiredRoles})
+}
+
+func (ac *AdminController) UpdateTwoFactorPolicy(c echo.Context) error {
+	var req models.TwoFactorPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	for _, role := range req.RequiredRoles {
+		if !utils.IsValidRole(role) {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRole, "Invalid role: "+role)
+		}
+	}
+
+	ctx := c.Request().Context()
+	settings, err := utils.GetSecuritySettings(ctx)
+	if err != nil {
+		return apperror.Internal("Failed to load security settings")
+	}
+	settings.TwoFactorRequiredRoles = req.RequiredRoles
+	if err := utils.SaveSecuritySettings(ctx, settings); err != nil {
+		return apperror.Internal("Failed to save security settings")
+	}
+
+	ac.audit(c, "security.2fa_policy", primitive.NilObjectID, map[string]interface{}{"requiredRoles": req.RequiredRoles})
+
+	return c.JSON(http.StatusOK, req)
+}
+
+func (ac *AdminController) ImpersonateUser(c echo.Context) error {
+	if _, nested := c.Get("impersonator_id").(primitive.ObjectID); nested {
+		return apperror.New(http.StatusForbidden, apperror.CodeImpersonationNotAllowed, "Cannot impersonate while impersonating")
+	}
+
+	user, err := ac.findUser(c)
+	if err != nil {
+		return err
+	}
+	if utils.HasPermission(user.Role, utils.PermUserImpersonate) {
+		return apperror.New(http.StatusForbidden, apperror.CodeImpersonationNotAllowed, "Cannot impersonate an administrator")
+	}
+	if !user.IsActive {
+		return apperror.New(http.StatusBadRequest, apperror.CodeUserDeactivated, "Cannot impersonate a deactivated user")
+	}
+
+	minutes := config.App.JWT.ImpersonationTTLMinutes
+	ttl := time.Duration(minutes) * time.Minute
+
+	adminID := c.Get("user_id").(primitive.ObjectID)
+	token, err := utils.GenerateImpersonationJWT(user.ID, user.Email, user.Role, adminID, ttl)
+	if err != nil {
+		return apperror.Internal("Failed to generate token")
+	}
+
+	ac.audit(c, "user.impersonate", user.ID, map[string]interface{}{"ttlMinutes": minutes})
+
+	user.Password = ""
+
+	return c.JSON(http.StatusOK, models.ImpersonationResponse{
+		Token:     token,
+		ExpiresAt: time.Now().Add(ttl),
+		User:      user,
+	})
+}
+
+func (ac *AdminController) targetID(c echo.Context) (primitive.ObjectID, error) {
+	targetID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return primitive.NilObjectID, apperror.New(http.StatusBadRequest, apperror.CodeInvalidUserID, "Invalid user ID")
+	}
+	if targetID == c.Get("user_id").(primitive.ObjectID) {
+		return primitive.NilObjectID, apperror.New(http.StatusBadRequest, apperror.CodeSelfActionNotAllowed, "You cannot perform this action on your own account")
+	}
+	return targetID, nil
+}
+
+// findUser loads the user named by the :id path parameter.
+func (ac *AdminController) findUser(c echo.Context) (models.User, error) {
+	var user models.User
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return user, apperror.New(http.StatusBadRequest, apperror.CodeInvalidUserID, "Invalid user ID")
+	}
+
+	err = ac.userCollection.FindOne(c.Request().Context(), bson.M{"_id": userID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return user, apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+		}
+		return user, apperror.Internal("Failed to fetch user").WithCause(err)
+	}
+	return user, nil
+}
+
+// updateUser applies set to the user and returns the updated document.
+func (ac *AdminController) updateUser(c echo.Context, userID primitive.ObjectID, set bson.M) (models.User, error) {
+	set["updated_at"] = time.Now()
+
+	var user models.User
+	err := ac.userCollection.FindOneAndUpdate(
+		c.Request().Context(),
+		bson.M{"_id": userID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return user, apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+		}
+		return user, apperror.Internal("Failed to update user").WithCause(err)
+	}
+
+	ctx := c.Request().Context()
+	cacheKeyProfile := "user:profile:" + user.ID.Hex()
+	cacheKeyEmail := "user:email:" + user.Email
+	utils.RedisClient.Del(ctx, cacheKeyProfile, cacheKeyEmail, "users:all")
+
+	user.Password = ""
+	return user, nil
+}
+
+func (ac *AdminController) audit(c echo.Context, action string, targetID primitive.ObjectID, details map[string]interface{}) {
+	recordAudit(ac.auditCollection, c, action, targetID, details)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxAPIKeysPerUser = 25
+
+type APIKeyController struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyController() *APIKeyController {
+	return &APIKeyController{
+		collection: utils.APIKeyCollection(),
+	}
+}
+
+func (kc *APIKeyController) CreateAPIKey(c echo.Context) error {
+	if _, viaKey := c.Get("api_key_id").(primitive.ObjectID); viaKey {
+		return apperror.New(http.StatusForbidden, apperror.CodeAPIKeyNotAllowed, "API keys cannot manage API keys")
+	}
+	userID := c.Get("user_id").(primitive.ObjectID)
+	userRole := c.Get("user_role").(string)
+	mfa, _ := c.Get("mfa").(bool)
+
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return apperror.Validation("name", "Name is required")
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{utils.ScopeAll}
+	}
+	for _, scope := range req.Scopes {
+		if !utils.IsGrantableScope(userRole, scope) {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidScope, "Invalid scope: "+scope)
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		return apperror.Validation("expires_in_days", "expires_in_days must not be negative")
+	}
+
+	ctx := c.Request().Context()
+	count, err := kc.collection.CountDocuments(ctx, bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}})
+	if err != nil {
+		return apperror.Internal("Failed to check API keys")
+	}
+	if count >= maxAPIKeysPerUser {
+		return apperror.New(http.StatusConflict, apperror.CodeAPIKeyLimitReached, "API key limit reached; revoke an existing key first")
+	}
+
+	key, prefix, err := utils.GenerateAPIKey()
+	if err != nil {
+		return apperror.Internal("Failed to generate API key")
+	}
+
+	apiKey := models.APIKey{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		Hash:      utils.HashToken(key),
+		Scopes:    req.Scopes,
+		MFA:       mfa,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := apiKey.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if _, err := kc.collection.InsertOne(ctx, apiKey); err != nil {
+		return apperror.Internal("Failed to create API key")
+	}
+
+	return c.JSON(http.StatusCreated, models.CreateAPIKeyResponse{
+		Key:    key,
+		APIKey: apiKey,
+	})
+}
+
+func (kc *APIKeyController) ListAPIKeys(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	ctx := c.Request().Context()
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := kc.collection.Find(ctx, bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return apperror.Internal("Failed to fetch API keys")
+	}
+	defer cursor.Close(ctx)
+
+	apiKeys := []models.APIKey{}
+	for cursor.Next(ctx) {
+		var apiKey models.APIKey
+		if err := cursor.Decode(&apiKey); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable API key document", "error", err)
+			continue
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+func (kc *APIKeyController) RevokeAPIKey(c echo.Context) error {
+	if _, viaKey := c.Get("api_key_id").(primitive.ObjectID); viaKey {
+		return apperror.New(http.StatusForbidden, apperror.CodeAPIKeyNotAllowed, "API keys cannot manage API keys")
+	}
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	keyID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidAPIKeyID, "Invalid API key ID")
+	}
+
+	var apiKey models.APIKey
+	ctx := c.Request().Context()
+	err = kc.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": keyID, "userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	).Decode(&apiKey)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return apperror.New(http.StatusNotFound, apperror.CodeAPIKeyNotFound, "API key not found")
+		}
+		return apperror.Internal("Failed to revoke API key")
+	}
+
+	utils.RedisClient.Del(ctx, utils.APIKeyCacheKey(apiKey.Hash))
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func recordAudit(collection *mongo.Collection, c echo.Context, action string, targetID primitive.ObjectID, details map[string]interface{}) {
+	actorID, _ := c.Get("user_id").(primitive.ObjectID)
+	if impersonatorID, ok := c.Get("impersonator_id").(primitive.ObjectID); ok {
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+		details["impersonatorId"] = impersonatorID
+	}
+
+	entry := models.AuditLog{
+		ID:        primitive.NewObjectID(),
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		IP:        c.RealIP(),
+		CreatedAt: time.Now(),
+	}
+	if _, err := collection.InsertOne(context.WithoutCancel(c.Request().Context()), entry); err != nil {
+		logging.For(c).Error("Failed to write audit log", "action", action, "error", err)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/config"
+	"PropertyListingSys/models"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	mimeProblemJSON   = "application/problem+json"
+	problemTypePrefix = "urn:propertylistingsys:error:"
+)
+
+// HTTPErrorHandler renders every error returned by a handler or middleware,
+// including Echo's own routing and binding errors, in one envelope. Clients
+// that send "Accept: application/problem+json", or every client when
+// ERROR_FORMAT=problem, get RFC 7807 problem details instead.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := toAppError(err)
+	requestID, _ := c.Get("request_id").(string)
+
+	if c.Request().Method == http.MethodHead {
+		c.NoContent(appErr.Status)
+		return
+	}
+
+	if wantsProblem(c) {
+		c.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+		c.JSON(appErr.Status, models.ProblemDetails{
+			Type:      problemTypePrefix + appErr.Code,
+			Title:     http.StatusText(appErr.Status),
+			Status:    appErr.Status,
+			Detail:    appErr.Message,
+			Instance:  c.Request().URL.Path,
+			Code:      appErr.Code,
+			Errors:    appErr.Details,
+			RequestID: requestID,
+		})
+		return
+	}
+
+	c.JSON(appErr.Status, models.ErrorResponse{
+		Error:     appErr.Message,
+		Code:      appErr.Code,
+		Details:   appErr.Details,
+		RequestID: requestID,
+	})
+}
+
+func toAppError(err error) *apperror.Error {
+	if appErr, ok := apperror.As(err); ok {
+		return appErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message, ok := httpErr.Message.(string)
+		if !ok || httpErr.Code >= 500 {
+			message = http.StatusText(httpErr.Code)
+		}
+		return apperror.New(httpErr.Code, echoErrorCode(httpErr.Code), message).WithCause(httpErr.Internal)
+	}
+
+	// Anything else is unexpected; its text may expose internals, so it is
+	// only logged.
+	return apperror.Internal("Internal server error").WithCause(err)
+}
+
+func echoErrorCode(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return apperror.CodeRouteNotFound
+	case http.StatusMethodNotAllowed:
+		return apperror.CodeMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		return apperror.CodeRequestTooLarge
+	case http.StatusUnsupportedMediaType:
+		return apperror.CodeUnsupportedMediaType
+	case http.StatusUnauthorized:
+		return apperror.CodeUnauthorized
+	case http.StatusForbidden:
+		return apperror.CodeForbidden
+	case http.StatusTooManyRequests:
+		return apperror.CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return apperror.CodeServiceUnavailable
+	}
+	if status >= 500 {
+		return apperror.CodeInternal
+	}
+	return apperror.CodeBadRequest
+}
+
+func wantsProblem(c echo.Context) bool {
+	if config.App.Errors.Format == "problem" {
+		return true
+	}
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), mimeProblemJSON)
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"PropertyListingSys/apperror"
 	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
 	"PropertyListingSys/models"
+	"PropertyListingSys/recommender"
 	"PropertyListingSys/utils"
-	"context"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -20,12 +21,8 @@ type FavoriteController struct {
 }
 
 func NewFavoriteController() *FavoriteController {
-	collectionName := os.Getenv("MONGODB_COLLECTION_FAVORITES")
-	if collectionName == "" {
-		collectionName = "favorites"
-	}
 	return &FavoriteController{
-		collection: config.GetCollection(collectionName),
+		collection: config.GetCollection(config.App.Mongo.Collections.Favorites),
 	}
 }
 
@@ -33,14 +30,14 @@ func (fc *FavoriteController) CreateFavorite(c echo.Context) error {
 	userID := c.Get("user_id").(primitive.ObjectID)
 	propertyID := c.FormValue("propertyId")
 	if !utils.IsValidExternalID(propertyID) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
 	}
-	count, err := fc.collection.CountDocuments(context.Background(), bson.M{"userId": userID, "propertyId": propertyID})
+	count, err := fc.collection.CountDocuments(c.Request().Context(), bson.M{"userId": userID, "propertyId": propertyID})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check favorite"})
+		return apperror.Internal("Failed to check favorite")
 	}
 	if count > 0 {
-		return c.JSON(http.StatusConflict, map[string]string{"error": "Property already favorited"})
+		return apperror.New(http.StatusConflict, apperror.CodeAlreadyFavorited, "Property already favorited")
 	}
 	favorite := models.Favorite{
 		ID:         primitive.NewObjectID(),
@@ -48,13 +45,13 @@ func (fc *FavoriteController) CreateFavorite(c echo.Context) error {
 		PropertyID: propertyID,
 		CreatedAt:  time.Now(),
 	}
-	_, err = fc.collection.InsertOne(context.Background(), favorite)
+	_, err = fc.collection.InsertOne(c.Request().Context(), favorite)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to favorite property"})
+		return apperror.Internal("Failed to favorite property")
 	}
 
 	cacheKey := "favorites:" + userID.Hex()
-	utils.RedisClient.Del(context.Background(), cacheKey)
+	utils.RedisClient.Del(c.Request().Context(), cacheKey, recommender.CacheKey(userID))
 
 	return c.JSON(http.StatusCreated, favorite)
 }
@@ -64,25 +61,27 @@ func (fc *FavoriteController) GetFavorites(c echo.Context) error {
 
 	var favorites []models.Favorite
 	cacheKey := "favorites:" + userID.Hex()
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &favorites); hit && err == nil {
 		return c.JSON(http.StatusOK, favorites)
 	}
 
 	cursor, err := fc.collection.Find(ctx, bson.M{"userId": userID})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch favorites"})
+		return apperror.Internal("Failed to fetch favorites")
 	}
 	defer cursor.Close(ctx)
 	for cursor.Next(ctx) {
 		var favorite models.Favorite
 		if err := cursor.Decode(&favorite); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable favorite document", "error", err)
 			continue
 		}
 		favorites = append(favorites, favorite)
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, favorites, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
 	}
 
 	return c.JSON(http.StatusOK, favorites)
@@ -92,15 +91,15 @@ func (fc *FavoriteController) DeleteFavorite(c echo.Context) error {
 	userID := c.Get("user_id").(primitive.ObjectID)
 	propertyID := c.Param("propertyId")
 	if !utils.IsValidExternalID(propertyID) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
 	}
-	_, err := fc.collection.DeleteOne(context.Background(), bson.M{"userId": userID, "propertyId": propertyID})
+	_, err := fc.collection.DeleteOne(c.Request().Context(), bson.M{"userId": userID, "propertyId": propertyID})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove favorite"})
+		return apperror.Internal("Failed to remove favorite")
 	}
 
 	cacheKey := "favorites:" + userID.Hex()
-	utils.RedisClient.Del(context.Background(), cacheKey)
+	utils.RedisClient.Del(c.Request().Context(), cacheKey, recommender.CacheKey(userID))
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Favorite removed successfully"})
 }
\ No newline at end of file
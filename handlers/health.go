@@ -1,7 +1,15 @@
 package handlers
 
 import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -9,3 +17,77 @@ import (
 func HealthCheck(c echo.Context) error {
 	return c.String(http.StatusOK, "Healthy!")
 }
+
+// Livez reports that the process is up and serving requests. It does not
+// check dependencies, so an outage of MongoDB or Redis does not get the
+// instance restarted.
+func Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz pings MongoDB and Redis and returns 503 if either is unreachable,
+// so traffic is only routed to instances that can serve it.
+func Readyz(c echo.Context) error {
+	checks := map[string]func(context.Context) error{
+		"mongodb": func(ctx context.Context) error {
+			return config.Client.Ping(ctx, nil)
+		},
+		"redis": func(ctx context.Context) error {
+			return utils.RedisClient.Ping(ctx).Err()
+		},
+	}
+
+	timeout := time.Duration(config.App.Server.ReadinessTimeoutMillis) * time.Millisecond
+	report := models.ReadinessReport{
+		Status:       "ok",
+		Dependencies: make(map[string]models.DependencyStatus, len(checks)),
+		Build:        utils.GetBuildInfo(),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			status := checkDependency(c.Request().Context(), name, timeout, check)
+			mu.Lock()
+			report.Dependencies[name] = status
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, status := range report.Dependencies {
+		if status.Status != "up" {
+			report.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(code, report)
+}
+
+func checkDependency(parent context.Context, name string, timeout time.Duration, check func(context.Context) error) models.DependencyStatus {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	status := models.DependencyStatus{
+		Status:    "up",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		// The underlying error can name internal hosts, so it is only logged.
+		logging.FromContext(parent).Warn("Readiness check failed", "dependency", name, "error", err)
+		status.Status = "down"
+		status.Error = "unreachable"
+		if errors.Is(err, context.DeadlineExceeded) {
+			status.Error = "timeout"
+		}
+	}
+	return status
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"PropertyListingSys/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func JWKS(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, utils.JWKS())
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/metrics"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	errFailedToFetchUser    = apperror.Internal("Failed to fetch user")
+	errFailedToLinkIdentity = apperror.Internal("Failed to link identity")
+	errFailedToCreateUser   = apperror.Internal("Failed to create user")
+)
+
+type oidcLoginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// LinkUserID is set when a signed-in user started the flow to link the
+	// provider identity to their account rather than to log in.
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+func oidcStateKey(state string) string {
+	return "oidc_state:" + utils.HashToken(state)
+}
+
+// OIDCLogin starts an authorization-code + PKCE flow by redirecting to the
+// provider. The state, nonce and code verifier stay server-side in Redis.
+func (uc *UserController) OIDCLogin(c echo.Context) error {
+	if !utils.OIDCEnabled() {
+		return apperror.New(http.StatusNotFound, apperror.CodeOIDCNotConfigured, "OIDC login is not configured")
+	}
+
+	authURL, err := startOIDCFlow(c, "")
+	if err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkOIDCIdentity starts the same flow for a signed-in user. The callback
+// then links the provider identity to that account instead of logging in.
+// The client sends the user to the returned URL.
+func (uc *UserController) LinkOIDCIdentity(c echo.Context) error {
+	if !utils.OIDCEnabled() {
+		return apperror.New(http.StatusNotFound, apperror.CodeOIDCNotConfigured, "OIDC login is not configured")
+	}
+
+	userID := c.Get("user_id").(primitive.ObjectID)
+	authURL, err := startOIDCFlow(c, userID.Hex())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]string{"authorization_url": authURL})
+}
+
+func startOIDCFlow(c echo.Context, linkUserID string) (string, error) {
+	ctx := c.Request().Context()
+	provider, err := utils.GetOIDCProvider(ctx)
+	if err != nil {
+		logging.For(c).Error("OIDC provider unavailable", "error", err)
+		return "", apperror.New(http.StatusBadGateway, apperror.CodeIdentityProviderUnavailable, "Identity provider is unavailable")
+	}
+
+	state, err1 := utils.GenerateToken(32)
+	nonce, err2 := utils.GenerateToken(32)
+	verifier, err3 := utils.GenerateToken(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return "", apperror.Internal("Failed to start OIDC login")
+	}
+
+	data, _ := json.Marshal(oidcLoginState{Nonce: nonce, Verifier: verifier, LinkUserID: linkUserID})
+	if err := utils.RedisClient.Set(ctx, oidcStateKey(state), data, oidcStateTTL).Err(); err != nil {
+		return "", apperror.Internal("Failed to start OIDC login")
+	}
+
+	return provider.AuthCodeURL(state, nonce, verifier), nil
+}
+
+// OIDCCallback completes the flow: it redeems the code, verifies the ID token
+// and signs the user in, linking or creating the local account as needed.
+func (uc *UserController) OIDCCallback(c echo.Context) error {
+	if !utils.OIDCEnabled() {
+		return apperror.New(http.StatusNotFound, apperror.CodeOIDCNotConfigured, "OIDC login is not configured")
+	}
+	if errCode := c.QueryParam("error"); errCode != "" {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeOIDCLoginFailed, "Identity provider denied login: "+errCode)
+	}
+
+	code := c.QueryParam("code")
+	state := c.QueryParam("state")
+	if code == "" || state == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeValidationFailed, "code and state are required")
+	}
+
+	ctx := c.Request().Context()
+	data, err := utils.RedisClient.GetDel(ctx, oidcStateKey(state)).Bytes()
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidLoginState, "Invalid or expired login state")
+	}
+	var loginState oidcLoginState
+	if err := json.Unmarshal(data, &loginState); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidLoginState, "Invalid or expired login state")
+	}
+
+	provider, err := utils.GetOIDCProvider(ctx)
+	if err != nil {
+		logging.For(c).Error("OIDC provider unavailable", "error", err)
+		return apperror.New(http.StatusBadGateway, apperror.CodeIdentityProviderUnavailable, "Identity provider is unavailable")
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, loginState.Verifier)
+	if err != nil {
+		logging.For(c).Warn("OIDC code exchange failed", "error", err)
+		metrics.RecordLogin("oidc", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeOIDCLoginFailed, "Failed to complete OIDC login")
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		logging.For(c).Warn("OIDC ID token rejected", "error", err)
+		metrics.RecordLogin("oidc", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeOIDCLoginFailed, "Failed to complete OIDC login")
+	}
+	claims.Email = utils.NormalizeEmail(claims.Email)
+	if claims.Email == "" || !claims.EmailVerified {
+		return apperror.New(http.StatusForbidden, apperror.CodeEmailNotVerified, "Your identity provider has not verified your email address")
+	}
+
+	if loginState.LinkUserID != "" {
+		return uc.linkOIDCIdentity(c, loginState.LinkUserID, provider.Issuer, claims)
+	}
+
+	user, err := uc.resolveOIDCUser(ctx, provider.Issuer, claims)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsActive {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeAccountDeactivated, "Account is deactivated")
+	}
+
+	if user.PasswordResetRequired {
+		return apperror.New(http.StatusForbidden, apperror.CodePasswordResetRequired, "Password reset required; check your email for a reset link")
+	}
+
+	if user.TOTPEnabled {
+		return uc.issueTwoFactorChallenge(c, user)
+	}
+
+	token, err := startSession(c, user, false)
+	if err != nil {
+		return apperror.Internal("Failed to generate token")
+	}
+	metrics.RecordLogin("oidc", metrics.LoginSuccess)
+
+	user.Password = ""
+
+	return c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  user,
+	})
+}
+
+// resolveOIDCUser finds the user already linked to the provider identity,
+// otherwise links the account with the same verified email, otherwise creates
+// a new passwordless account.
+func (uc *UserController) resolveOIDCUser(ctx context.Context, issuer string, claims *utils.OIDCClaims) (models.User, error) {
+	var user models.User
+	err := uc.collection.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": claims.Subject}},
+	}).Decode(&user)
+	if err == nil {
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, errFailedToFetchUser.WithCause(err)
+	}
+
+	identity := models.FederatedIdentity{
+		Issuer:   issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+
+	err = uc.collection.FindOne(ctx, bson.M{"email": claims.Email}).Decode(&user)
+	if err == nil {
+		// Anyone can register a password account for an address they do not
+		// own. Linking to such an account would leave their password working
+		// on the real owner's account, so the owner has to sign in and link
+		// the provider themselves.
+		if user.Password != "" && !user.EmailVerified {
+			return user, apperror.New(http.StatusConflict, apperror.CodeAccountLinkRequired, "An account with this email already exists; sign in with your password and link your identity provider from your account")
+		}
+		_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$push": bson.M{"identities": identity},
+			"$set":  bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			return user, errFailedToLinkIdentity.WithCause(err)
+		}
+		user.Identities = append(user.Identities, identity)
+		utils.RedisClient.Del(ctx, "user:profile:"+user.ID.Hex())
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, errFailedToFetchUser.WithCause(err)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+	user = models.User{
+		ID:            primitive.NewObjectID(),
+		Email:         claims.Email,
+		Name:          name,
+		Role:          utils.RoleUser,
+		IsActive:      true,
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		Identities:    []models.FederatedIdentity{identity},
+	}
+	if _, err := uc.collection.InsertOne(ctx, user); err != nil {
+		return user, errFailedToCreateUser.WithCause(err)
+	}
+
+	utils.RedisClient.Del(ctx, "users:all")
+	uc.attachPendingRecommendations(ctx, user)
+	return user, nil
+}
+
+// linkOIDCIdentity attaches the provider identity to the user who started
+// the flow from LinkOIDCIdentity.
+func (uc *UserController) linkOIDCIdentity(c echo.Context, userHex, issuer string, claims *utils.OIDCClaims) error {
+	ctx := c.Request().Context()
+	userID, err := primitive.ObjectIDFromHex(userHex)
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidLoginState, "Invalid or expired login state")
+	}
+
+	var owner models.User
+	err = uc.collection.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": claims.Subject}},
+	}).Decode(&owner)
+	if err == nil {
+		if owner.ID == userID {
+			return c.JSON(http.StatusOK, map[string]string{"message": "Identity provider already linked"})
+		}
+		return apperror.New(http.StatusConflict, apperror.CodeIdentityAlreadyLinked, "This identity is already linked to another account")
+	}
+	if err != mongo.ErrNoDocuments {
+		return errFailedToFetchUser.WithCause(err)
+	}
+
+	result, err := uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$push": bson.M{"identities": models.FederatedIdentity{
+			Issuer:   issuer,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+			LinkedAt: time.Now(),
+		}},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return errFailedToLinkIdentity.WithCause(err)
+	}
+	if result.MatchedCount == 0 {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Identity provider linked"})
+}
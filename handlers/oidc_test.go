@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/config"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const (
+	testOIDCClientID = "property-listing"
+	testOIDCKeyID    = "test-key"
+)
+
+// mockIssuer is a minimal OpenID provider. Codes are registered by the test
+// with the PKCE challenge and ID token claims they were issued for; the token
+// endpoint checks the verifier against that challenge.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// The provider is discovered once per process, so every test shares one
+// issuer.
+var testIssuer = sync.OnceValue(func() *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	m := &mockIssuer{key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub := m.key.PublicKey
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testOIDCKeyID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", m.token)
+	m.server = httptest.NewServer(mux)
+	return m
+})
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	grant, ok := m.grants[r.FormValue("code")]
+	delete(m.grants, r.FormValue("code"))
+	m.mu.Unlock()
+
+	if !ok || utils.PKCEChallenge(r.FormValue("code_verifier")) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = testOIDCKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
+
+// authorize plays the user approving the login at the provider: it issues a
+// code for the authorization request in location and returns the callback
+// query. edit may change the grant before it is stored.
+func (m *mockIssuer) authorize(t *testing.T, location, email string, edit func(*mockGrant)) url.Values {
+	t.Helper()
+	authURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	params := authURL.Query()
+
+	now := time.Now()
+	grant := mockGrant{
+		challenge: params.Get("code_challenge"),
+		claims: jwt.MapClaims{
+			"iss":            m.server.URL,
+			"aud":            testOIDCClientID,
+			"sub":            "subject-" + email,
+			"email":          email,
+			"email_verified": true,
+			"name":           "Test User",
+			"nonce":          params.Get("nonce"),
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Minute).Unix(),
+		},
+	}
+	if edit != nil {
+		edit(&grant)
+	}
+
+	code, err := utils.GenerateToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.grants[code] = grant
+	m.mu.Unlock()
+
+	return url.Values{"code": {code}, "state": {params.Get("state")}}
+}
+
+func setupOIDCTest(t *testing.T) *mockIssuer {
+	issuer := testIssuer()
+
+	t.Setenv("MONGODB_URI", "mongodb://localhost:27017")
+	t.Setenv("MONGODB_DATABASE", "test")
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALLOWED_ALGS", "HS256")
+	t.Setenv("OIDC_ISSUER", issuer.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", testOIDCClientID)
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost/api/auth/oidc/callback")
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	config.App = cfg
+	if err := utils.InitJWTKeys(); err != nil {
+		t.Fatalf("init JWT keys: %v", err)
+	}
+
+	utils.RedisClient = redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	return issuer
+}
+
+// oidcLogin runs OIDCLogin and the provider's authorization, then calls
+// OIDCCallback with the result.
+func oidcLogin(t *testing.T, uc *UserController, issuer *mockIssuer, email string, edit func(*mockGrant)) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	e := echo.New()
+
+	rec := httptest.NewRecorder()
+	if err := uc.OIDCLogin(e.NewContext(httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil), rec)); err != nil {
+		t.Fatalf("OIDCLogin: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("OIDCLogin status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	query := issuer.authorize(t, rec.Header().Get("Location"), email, edit)
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+query.Encode(), nil)
+	return rec, uc.OIDCCallback(e.NewContext(req, rec))
+}
+
+func assertErrorCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		t.Fatalf("error = %v, want code %s", err, code)
+	}
+	if appErr.Code != code {
+		t.Fatalf("error code = %s (%v), want %s", appErr.Code, appErr, code)
+	}
+}
+
+func decodeLogin(t *testing.T, rec *httptest.ResponseRecorder) models.LoginResponse {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp models.LoginResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Token == "" {
+		t.Fatal("response has no token")
+	}
+	return resp
+}
+
+// commandDoc returns the first command named name that the client sent.
+func commandDoc(mt *mtest.T, name string) bson.Raw {
+	mt.Helper()
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("no %s command was sent", name)
+	return nil
+}
+
+func TestOIDCCallback(t *testing.T) {
+	issuer := setupOIDCTest(t)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ns := "test.users"
+	noUser := func() bson.D { return mtest.CreateCursorResponse(0, ns, mtest.FirstBatch) }
+
+	mt.Run("rejects a code redeemed with another PKCE verifier", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+
+		_, err := oidcLogin(mt.T, uc, issuer, "pkce@example.com", func(g *mockGrant) {
+			g.challenge = utils.PKCEChallenge("attacker-verifier")
+		})
+		assertErrorCode(mt.T, err, apperror.CodeOIDCLoginFailed)
+	})
+
+	mt.Run("rejects an ID token with another nonce", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+
+		_, err := oidcLogin(mt.T, uc, issuer, "nonce@example.com", func(g *mockGrant) {
+			g.claims["nonce"] = "replayed-nonce"
+		})
+		assertErrorCode(mt.T, err, apperror.CodeOIDCLoginFailed)
+	})
+
+	mt.Run("links an existing account by verified email", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			noUser(),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "email", Value: "existing@example.com"},
+				{Key: "name", Value: "Existing User"},
+				{Key: "role", Value: utils.RoleUser},
+				{Key: "is_active", Value: true},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		rec, err := oidcLogin(mt.T, uc, issuer, "existing@example.com", nil)
+		if err != nil {
+			mt.Fatalf("OIDCCallback: %v", err)
+		}
+		resp := decodeLogin(mt.T, rec)
+		if resp.User.ID != userID {
+			mt.Errorf("user ID = %s, want existing %s", resp.User.ID.Hex(), userID.Hex())
+		}
+
+		update := commandDoc(mt, "update")
+		pushed := update.Lookup("updates", "0", "u", "$push", "identities", "subject").StringValue()
+		if pushed != "subject-existing@example.com" {
+			mt.Errorf("linked subject = %q, want the ID token's subject", pushed)
+		}
+	})
+
+	mt.Run("refuses to link a password account with an unverified email", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+		mt.AddMockResponses(
+			noUser(),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "email", Value: "squatted@example.com"},
+				{Key: "password", Value: "attacker-hash"},
+				{Key: "role", Value: utils.RoleUser},
+				{Key: "is_active", Value: true},
+			}),
+		)
+
+		_, err := oidcLogin(mt.T, uc, issuer, "squatted@example.com", nil)
+		assertErrorCode(mt.T, err, apperror.CodeAccountLinkRequired)
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "update" {
+				mt.Fatal("identity was linked to the unverified account")
+			}
+		}
+	})
+
+	mt.Run("links the identity to the signed-in user", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			noUser(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/api/users/oidc/link", nil), rec)
+		c.Set("user_id", userID)
+		if err := uc.LinkOIDCIdentity(c); err != nil {
+			mt.Fatalf("LinkOIDCIdentity: %v", err)
+		}
+		var started map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+			mt.Fatalf("decode response: %v", err)
+		}
+
+		query := issuer.authorize(mt.T, started["authorization_url"], "other@example.com", nil)
+		rec = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+query.Encode(), nil)
+		if err := uc.OIDCCallback(e.NewContext(req, rec)); err != nil {
+			mt.Fatalf("OIDCCallback: %v", err)
+		}
+		if rec.Code != http.StatusOK {
+			mt.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+
+		update := commandDoc(mt, "update").Lookup("updates", "0")
+		if id := update.Document().Lookup("q", "_id").ObjectID(); id != userID {
+			mt.Errorf("linked to %s, want signed-in user %s", id.Hex(), userID.Hex())
+		}
+		if subject := update.Document().Lookup("u", "$push", "identities", "subject").StringValue(); subject != "subject-other@example.com" {
+			mt.Errorf("linked subject = %q, want the ID token's subject", subject)
+		}
+	})
+
+	mt.Run("creates an account for a new email", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+		mt.AddMockResponses(
+			noUser(),
+			noUser(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		rec, err := oidcLogin(mt.T, uc, issuer, "new@example.com", nil)
+		if err != nil {
+			mt.Fatalf("OIDCCallback: %v", err)
+		}
+		resp := decodeLogin(mt.T, rec)
+		if resp.User.Email != "new@example.com" || resp.User.Role != utils.RoleUser {
+			mt.Errorf("user = %s with role %s, want new@example.com with role %s", resp.User.Email, resp.User.Role, utils.RoleUser)
+		}
+
+		inserted := commandDoc(mt, "insert").Lookup("documents", "0")
+		if email := inserted.Document().Lookup("email").StringValue(); email != "new@example.com" {
+			mt.Errorf("inserted email = %q, want new@example.com", email)
+		}
+		if subject := inserted.Document().Lookup("identities", "0", "subject").StringValue(); subject != "subject-new@example.com" {
+			mt.Errorf("inserted identity subject = %q, want the ID token's subject", subject)
+		}
+	})
+
+	mt.Run("refuses a linked account that must reset its password", func(mt *mtest.T) {
+		config.DB = mt.DB
+		uc := NewUserController()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "email", Value: "reset@example.com"},
+			{Key: "role", Value: utils.RoleUser},
+			{Key: "is_active", Value: true},
+			{Key: "password_reset_required", Value: true},
+		}))
+
+		_, err := oidcLogin(mt.T, uc, issuer, "reset@example.com", nil)
+		assertErrorCode(mt.T, err, apperror.CodePasswordResetRequired)
+	})
+}
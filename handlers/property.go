@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"PropertyListingSys/apperror"
 	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
 	"PropertyListingSys/models"
+	"PropertyListingSys/recommender"
 	"PropertyListingSys/utils"
 	"context"
+	"fmt"
+	"math"
 	"net/http"
-	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -18,16 +24,16 @@ import (
 )
 
 type PropertyController struct {
-	collection *mongo.Collection
+	collection     *mongo.Collection
+	viewCollection *mongo.Collection
+	engine         *recommender.Engine
 }
 
 func NewPropertyController() *PropertyController {
-	collectionName := os.Getenv("MONGODB_COLLECTION_PROPERTIES")
-	if collectionName == "" {
-		collectionName = "properties"
-	}
 	return &PropertyController{
-		collection: config.GetCollection(collectionName),
+		collection:     config.GetCollection(config.App.Mongo.Collections.Properties),
+		viewCollection: config.GetCollection(config.App.Mongo.Collections.Views),
+		engine:         recommender.NewEngine(),
 	}
 }
 
@@ -35,30 +41,31 @@ func (pc *PropertyController) CreateProperty(c echo.Context) error {
 	userID := c.Get("user_id").(primitive.ObjectID)
 	var property models.Property
 	if err := c.Bind(&property); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
 	}
 
 	if !utils.IsValidExternalID(property.ExternalID) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid externalId: must be PROP followed by a number greater than 1000"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidExternalID, "Invalid externalId: must be PROP followed by a number greater than 1000").WithDetails(apperror.FieldError{Field: "externalId", Message: "must be PROP followed by a number greater than 1000"})
 	}
 
-	count, err := pc.collection.CountDocuments(context.Background(), bson.M{"_id": property.ExternalID})
+	count, err := pc.collection.CountDocuments(c.Request().Context(), bson.M{"_id": property.ExternalID})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check property existence"})
+		return apperror.Internal("Failed to check property existence")
 	}
 	if count > 0 {
-		return c.JSON(http.StatusConflict, map[string]string{"error": "Property with this externalId already exists"})
+		return apperror.New(http.StatusConflict, apperror.CodePropertyExists, "Property with this externalId already exists")
 	}
 
 	property.CreatedBy = &userID
 	property.CreatedAt = time.Now()
 	property.UpdatedAt = time.Now()
-	_, err = pc.collection.InsertOne(context.Background(), property)
+	_, err = pc.collection.InsertOne(c.Request().Context(), property)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create property"})
+		return apperror.Internal("Failed to create property")
 	}
 
-	utils.RedisClient.Del(context.Background(), "properties:*")
+	utils.InvalidatePropertyLists(c.Request().Context())
+	recommender.InvalidateSimilar(c.Request().Context())
 
 	return c.JSON(http.StatusCreated, property)
 }
@@ -66,140 +73,400 @@ func (pc *PropertyController) CreateProperty(c echo.Context) error {
 func (pc *PropertyController) GetProperty(c echo.Context) error {
 	id := c.Param("id")
 	if !utils.IsValidExternalID(id) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
 	}
 
 	var property models.Property
 	cacheKey := "property:" + id
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &property); hit && err == nil {
+		pc.recordView(c, id)
 		return c.JSON(http.StatusOK, property)
 	}
 
 	err := pc.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&property)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Property not found"})
+			return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Property not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch property"})
+		return apperror.Internal("Failed to fetch property")
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, property, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
 	}
+	pc.recordView(c, id)
 
 	return c.JSON(http.StatusOK, property)
 }
 
-func (pc *PropertyController) PatchProperty(c echo.Context) error {
-	userID := c.Get("user_id").(primitive.ObjectID)
-	userRole := c.Get("user_role").(string)
+func (pc *PropertyController) GetSimilarProperties(c echo.Context) error {
 	id := c.Param("id")
 	if !utils.IsValidExternalID(id) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
+	}
+
+	limit := 10
+	if l := c.QueryParam("limit"); l != "" {
+		num, err := strconv.Atoi(l)
+		if err != nil || num <= 0 {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidQueryParameter, "Invalid limit").WithDetails(apperror.FieldError{Field: "limit", Message: "must be a positive integer"})
+		}
+		limit = num
+	}
+	if limit > recommender.MaxSimilar {
+		limit = recommender.MaxSimilar
 	}
 
 	var property models.Property
-	err := pc.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&property)
+	ctx := c.Request().Context()
+	cacheKey := "property:" + id
+	if hit, err := utils.GetCached(ctx, cacheKey, &property); !hit || err != nil {
+		err := pc.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&property)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Property not found")
+			}
+			return apperror.Internal("Failed to fetch property")
+		}
+		if err := utils.SetCached(ctx, cacheKey, property, 30*time.Second); err != nil {
+			logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
+		}
+	}
+
+	similar, err := pc.engine.Similar(ctx, property, limit)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Property not found"})
+		return apperror.Internal("Failed to find similar properties")
+	}
+
+	return c.JSON(http.StatusOK, similar)
+}
+
+func (pc *PropertyController) CompareProperties(c echo.Context) error {
+	maxCompare := config.App.Properties.CompareMaxProperties
+
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range strings.Split(c.QueryParam("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch property"})
+		if !utils.IsValidExternalID(id) {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID: "+id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidComparison, "At least two property IDs are required")
+	}
+	if len(ids) > maxCompare {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidComparison, "At most "+strconv.Itoa(maxCompare)+" properties can be compared")
 	}
 
-	if (property.CreatedBy != nil && *property.CreatedBy != userID && userRole != "admin") || (property.CreatedBy == nil && userRole != "admin") {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not authorized to update this property"})
+	ctx := c.Request().Context()
+	cursor, err := pc.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return apperror.Internal("Failed to fetch properties")
 	}
+	defer cursor.Close(ctx)
 
-	var update map[string]interface{}
-	if err := c.Bind(&update); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	byID := make(map[string]models.Property, len(ids))
+	for cursor.Next(ctx) {
+		var property models.Property
+		if err := cursor.Decode(&property); err != nil {
+			return apperror.Internal("Failed to decode property").WithCause(err)
+		}
+		byID[property.ExternalID] = property
+	}
+	if err := cursor.Err(); err != nil {
+		return apperror.Internal("Failed to fetch properties").WithCause(err)
 	}
 
-	updateDoc := bson.M{"updatedAt": time.Now()}
-	allowedFields := map[string]bool{
-		"title":         true,
-		"type":          true,
-		"price":         true,
-		"state":         true,
-		"city":          true,
-		"areaSqFt":      true,
-		"bedrooms":      true,
-		"bathrooms":     true,
-		"amenities":     true,
-		"furnished":     true,
-		"availableFrom": true,
-		"listedBy":      true,
-		"tags":          true,
-		"colorTheme":    true,
-		"rating":        true,
-		"isVerified":    true,
-		"listingType":   true,
+	properties := make([]models.Property, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		property, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		properties = append(properties, property)
+	}
+	if len(missing) > 0 {
+		return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Properties not found: "+strings.Join(missing, ","))
 	}
 
-	for key, value := range update {
-		if allowedFields[key] {
-			if key == "availableFrom" {
-				if str, ok := value.(string); ok {
-					if t, err := time.Parse(time.RFC3339, str); err == nil {
-						updateDoc[key] = t
-					} else {
-						return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid availableFrom format"})
-					}
-				}
-			} else {
-				updateDoc[key] = value
+	return c.JSON(http.StatusOK, compareProperties(properties))
+}
+
+func compareProperties(properties []models.Property) models.PropertyComparison {
+	fieldValues := []struct {
+		name  string
+		value func(p models.Property) interface{}
+		// key, when set, decides whether two values differ; otherwise their
+		// printed forms are compared.
+		key func(p models.Property) string
+	}{
+		{"title", func(p models.Property) interface{} { return p.Title }, nil},
+		{"type", func(p models.Property) interface{} { return p.Type }, nil},
+		{"price", func(p models.Property) interface{} { return p.Price }, nil},
+		{"state", func(p models.Property) interface{} { return p.State }, nil},
+		{"city", func(p models.Property) interface{} { return p.City }, nil},
+		{"areaSqFt", func(p models.Property) interface{} { return p.AreaSqFt }, nil},
+		{"bedrooms", func(p models.Property) interface{} { return p.Bedrooms }, nil},
+		{"bathrooms", func(p models.Property) interface{} { return p.Bathrooms }, nil},
+		{"amenities", func(p models.Property) interface{} { return p.Amenities }, func(p models.Property) string { return listSetKey(p.AmenityList()) }},
+		{"furnished", func(p models.Property) interface{} { return p.Furnished }, nil},
+		{"availableFrom", func(p models.Property) interface{} { return p.AvailableFrom }, nil},
+		{"listedBy", func(p models.Property) interface{} { return p.ListedBy }, nil},
+		{"tags", func(p models.Property) interface{} { return p.Tags }, func(p models.Property) string { return listSetKey(p.TagList()) }},
+		{"rating", func(p models.Property) interface{} { return p.Rating }, nil},
+		{"isVerified", func(p models.Property) interface{} { return p.IsVerified }, nil},
+		{"listingType", func(p models.Property) interface{} { return p.ListingType }, nil},
+	}
+
+	comparison := models.PropertyComparison{
+		Properties: properties,
+		Metrics:    make(map[string]models.ComparisonMetrics, len(properties)),
+		Amenities: models.AmenityComparison{
+			Unique: make(map[string][]string, len(properties)),
+		},
+	}
+
+	for _, field := range fieldValues {
+		key := field.key
+		if key == nil {
+			key = func(p models.Property) string { return fmt.Sprint(field.value(p)) }
+		}
+		compared := models.ComparedField{Field: field.name}
+		for i, property := range properties {
+			compared.Values = append(compared.Values, field.value(property))
+			if i > 0 && key(property) != key(properties[0]) {
+				compared.Different = true
 			}
 		}
+		comparison.Fields = append(comparison.Fields, compared)
 	}
 
-	if len(updateDoc) <= 1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No valid fields to update"})
+	shared := properties[0].AmenityList()
+	all := map[string]bool{}
+	for _, property := range properties {
+		amenities := property.AmenityList()
+		shared = recommender.Intersect(shared, amenities)
+		for _, amenity := range amenities {
+			all[amenity] = true
+		}
+
+		var pricePerSqFt float64
+		if property.AreaSqFt > 0 {
+			pricePerSqFt = math.Round(property.Price/property.AreaSqFt*100) / 100
+		}
+		comparison.Metrics[property.ExternalID] = models.ComparisonMetrics{
+			PricePerSqFt: pricePerSqFt,
+			AmenityCount: len(amenities),
+		}
+	}
+
+	inShared := make(map[string]bool, len(shared))
+	for _, amenity := range shared {
+		inShared[amenity] = true
+	}
+	for _, property := range properties {
+		unique := []string{}
+		for _, amenity := range property.AmenityList() {
+			if !inShared[amenity] {
+				unique = append(unique, amenity)
+			}
+		}
+		comparison.Amenities.Unique[property.ExternalID] = unique
+	}
+
+	comparison.Amenities.Shared = shared
+	if len(all) > 0 {
+		comparison.Amenities.Overlap = math.Round(float64(len(shared))/float64(len(all))*1000) / 1000
 	}
 
-	_, err = pc.collection.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": updateDoc})
+	return comparison
+}
+
+// listSetKey identifies a list by its distinct items, ignoring order, so
+// "Pool|Gym" and "gym, pool" compare equal.
+func listSetKey(items []string) string {
+	set := slices.Clone(items)
+	slices.Sort(set)
+	return strings.Join(slices.Compact(set), "|")
+}
+
+func (pc *PropertyController) recordView(c echo.Context, propertyID string) {
+	userID, ok := c.Get("user_id").(primitive.ObjectID)
+	if !ok {
+		return
+	}
+	view := models.PropertyView{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		PropertyID: propertyID,
+		ViewedAt:   time.Now(),
+	}
+	// The insert runs in the background so it does not delay the response;
+	// MONGODB_OPERATION_TIMEOUT_MS still bounds it.
+	ctx := context.WithoutCancel(c.Request().Context())
+	logger := logging.For(c)
+	go func() {
+		if _, err := pc.viewCollection.InsertOne(ctx, view); err != nil {
+			logger.Warn("Failed to record property view", "property_id", propertyID, "error", err)
+		}
+	}()
+}
+
+func (pc *PropertyController) PatchProperty(c echo.Context) error {
+	id := c.Param("id")
+	if !utils.IsValidExternalID(id) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
+	}
+
+	var property models.Property
+	err := pc.collection.FindOne(c.Request().Context(), bson.M{"_id": id}).Decode(&property)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Property not found")
+		}
+		return apperror.Internal("Failed to fetch property")
+	}
+
+	if !canUpdateProperty(c, property) {
+		return apperror.New(http.StatusForbidden, apperror.CodeNotPropertyOwner, "You are not authorized to update this property")
+	}
+
+	var update map[string]interface{}
+	if err := c.Bind(&update); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	updateDoc, err := buildPropertyUpdate(update)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update property"})
+		return err
 	}
 
-	err = pc.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&property)
+	_, err = pc.collection.UpdateOne(c.Request().Context(), bson.M{"_id": id}, bson.M{"$set": updateDoc})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch updated property"})
+		return apperror.Internal("Failed to update property")
+	}
+
+	err = pc.collection.FindOne(c.Request().Context(), bson.M{"_id": id}).Decode(&property)
+	if err != nil {
+		return apperror.Internal("Failed to fetch updated property")
 	}
 
 	cacheKey := "property:" + id
-	utils.RedisClient.Del(context.Background(), cacheKey)
-	utils.RedisClient.Del(context.Background(), "properties:*")
+	utils.RedisClient.Del(c.Request().Context(), cacheKey)
+	recommender.InvalidateSimilar(c.Request().Context())
+	utils.InvalidatePropertyLists(c.Request().Context())
 
 	return c.JSON(http.StatusOK, property)
 }
 
-func (pc *PropertyController) DeleteProperty(c echo.Context) error {
+func canUpdateProperty(c echo.Context, property models.Property) bool {
+	return canModifyProperty(c, property, utils.PermPropertyUpdateOwn, utils.PermPropertyUpdateAny)
+}
+
+func canDeleteProperty(c echo.Context, property models.Property) bool {
+	return canModifyProperty(c, property, utils.PermPropertyDeleteOwn, utils.PermPropertyDeleteAny)
+}
+
+func canModifyProperty(c echo.Context, property models.Property, ownPermission, anyPermission string) bool {
+	if hasPermission(c, anyPermission) {
+		return true
+	}
 	userID := c.Get("user_id").(primitive.ObjectID)
-	userRole := c.Get("user_role").(string)
+	return property.CreatedBy != nil && *property.CreatedBy == userID && hasPermission(c, ownPermission)
+}
+
+// hasPermission checks the caller's role and, for API key requests, the
+// key's scopes.
+func hasPermission(c echo.Context, permission string) bool {
+	role, _ := c.Get("user_role").(string)
+	if !utils.HasPermission(role, permission) {
+		return false
+	}
+	if scopes, ok := c.Get("api_key_scopes").([]string); ok {
+		return utils.HasScope(scopes, permission)
+	}
+	return true
+}
+
+var patchableFields = map[string]bool{
+	"title":         true,
+	"type":          true,
+	"price":         true,
+	"state":         true,
+	"city":          true,
+	"areaSqFt":      true,
+	"bedrooms":      true,
+	"bathrooms":     true,
+	"amenities":     true,
+	"furnished":     true,
+	"availableFrom": true,
+	"listedBy":      true,
+	"tags":          true,
+	"colorTheme":    true,
+	"rating":        true,
+	"isVerified":    true,
+	"listingType":   true,
+}
+
+func buildPropertyUpdate(update map[string]interface{}) (bson.M, error) {
+	updateDoc := bson.M{"updatedAt": time.Now()}
+	for key, value := range update {
+		if !patchableFields[key] {
+			continue
+		}
+		if key == "availableFrom" {
+			if str, ok := value.(string); ok {
+				t, err := time.Parse(time.RFC3339, str)
+				if err != nil {
+					return nil, apperror.Validation("availableFrom", "Invalid availableFrom format: use RFC 3339")
+				}
+				updateDoc[key] = t
+			}
+			continue
+		}
+		updateDoc[key] = value
+	}
+
+	if len(updateDoc) <= 1 {
+		return nil, apperror.New(http.StatusBadRequest, apperror.CodeValidationFailed, "No valid fields to update")
+	}
+	return updateDoc, nil
+}
+
+func (pc *PropertyController) DeleteProperty(c echo.Context) error {
 	id := c.Param("id")
 	if !utils.IsValidExternalID(id) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
 	}
 	var property models.Property
-	err := pc.collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&property)
+	err := pc.collection.FindOne(c.Request().Context(), bson.M{"_id": id}).Decode(&property)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Property not found"})
+			return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Property not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch property"})
+		return apperror.Internal("Failed to fetch property")
 	}
-	if (property.CreatedBy != nil && *property.CreatedBy != userID) || (property.CreatedBy == nil && userRole != "admin") {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not authorized to delete this property"})
+	if !canDeleteProperty(c, property) {
+		return apperror.New(http.StatusForbidden, apperror.CodeNotPropertyOwner, "You are not authorized to delete this property")
 	}
-	_, err = pc.collection.DeleteOne(context.Background(), bson.M{"_id": id})
+	_, err = pc.collection.DeleteOne(c.Request().Context(), bson.M{"_id": id})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete property"})
+		return apperror.Internal("Failed to delete property")
 	}
 
 	cacheKey := "property:" + id
-	utils.RedisClient.Del(context.Background(), cacheKey)
-	utils.RedisClient.Del(context.Background(), "properties:*")
+	utils.RedisClient.Del(c.Request().Context(), cacheKey)
+	recommender.InvalidateSimilar(c.Request().Context())
+	utils.InvalidatePropertyLists(c.Request().Context())
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Property deleted successfully"})
 }
@@ -342,7 +609,7 @@ func (pc *PropertyController) ListProperties(c echo.Context) error {
 
 	var properties []models.Property
 	cacheKey := utils.GenerateQueryCacheKey("properties", queryParams)
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &properties); hit && err == nil {
 		return c.JSON(http.StatusOK, properties)
 	}
@@ -350,18 +617,20 @@ func (pc *PropertyController) ListProperties(c echo.Context) error {
 	options := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
 	cursor, err := pc.collection.Find(ctx, query, options)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch properties"})
+		return apperror.Internal("Failed to fetch properties")
 	}
 	defer cursor.Close(ctx)
 	for cursor.Next(ctx) {
 		var property models.Property
 		if err := cursor.Decode(&property); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable property document", "error", err)
 			continue
 		}
 		properties = append(properties, property)
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, properties, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
 	}
 
 	return c.JSON(http.StatusOK, properties)
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/recommender"
+	"PropertyListingSys/utils"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func batchMaxItems() int {
+	return config.App.Properties.BatchMaxItems
+}
+
+func (pc *PropertyController) BatchGetProperties(c echo.Context) error {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range strings.Split(c.QueryParam("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		if !utils.IsValidExternalID(id) {
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID: "+id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return apperror.Validation("ids", "ids is required")
+	}
+	if len(ids) > batchMaxItems() {
+		return apperror.New(http.StatusBadRequest, apperror.CodeBatchTooLarge, "Too many IDs: maximum is "+strconv.Itoa(batchMaxItems()))
+	}
+
+	byID, err := pc.findByIDs(c.Request().Context(), ids)
+	if err != nil {
+		return apperror.Internal("Failed to fetch properties")
+	}
+
+	properties := make([]models.Property, 0, len(ids))
+	missing := []string{}
+	for _, id := range ids {
+		if property, ok := byID[id]; ok {
+			properties = append(properties, property)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return c.JSON(http.StatusOK, models.BatchGetResponse{
+		Properties: properties,
+		Missing:    missing,
+	})
+}
+
+func (pc *PropertyController) BatchCreateProperties(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var req models.BatchCreateRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if len(req.Properties) == 0 {
+		return apperror.Validation("properties", "properties is required")
+	}
+	if len(req.Properties) > batchMaxItems() {
+		return apperror.New(http.StatusBadRequest, apperror.CodeBatchTooLarge, "Too many properties: maximum is "+strconv.Itoa(batchMaxItems()))
+	}
+
+	ctx := c.Request().Context()
+	results := make([]models.BatchItemResult, len(req.Properties))
+	ids := make([]string, 0, len(req.Properties))
+	seen := map[string]bool{}
+	for i, property := range req.Properties {
+		results[i].ExternalID = property.ExternalID
+		switch {
+		case !utils.IsValidExternalID(property.ExternalID):
+			results[i].Status = models.BatchStatusInvalid
+			results[i].Error = "Invalid externalId: must be PROP followed by a number greater than 1000"
+		case seen[property.ExternalID]:
+			results[i].Status = models.BatchStatusInvalid
+			results[i].Error = "Duplicate externalId in batch"
+		default:
+			seen[property.ExternalID] = true
+			ids = append(ids, property.ExternalID)
+		}
+	}
+
+	existing, err := pc.findByIDs(ctx, ids)
+	if err != nil {
+		return apperror.Internal("Failed to check property existence")
+	}
+
+	now := time.Now()
+	var writes []mongo.WriteModel
+	var writeItems []int
+	owners := map[string]*primitive.ObjectID{}
+	for i, property := range req.Properties {
+		if results[i].Status != "" {
+			continue
+		}
+
+		current, exists := existing[property.ExternalID]
+		switch {
+		case exists && !req.Upsert:
+			results[i].Status = models.BatchStatusConflict
+			results[i].Error = "Property with this externalId already exists"
+			continue
+		case exists && !canUpdateProperty(c, current):
+			results[i].Status = models.BatchStatusForbidden
+			results[i].Error = "You are not authorized to update this property"
+			continue
+		case exists:
+			property.CreatedBy = current.CreatedBy
+			property.CreatedAt = current.CreatedAt
+			property.UpdatedAt = now
+			// Matching on the owner too means a listing deleted or recreated
+			// by someone else since the check above is not overwritten.
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": property.ExternalID, "createdBy": current.CreatedBy}).
+				SetReplacement(property))
+			owners[property.ExternalID] = current.CreatedBy
+			results[i].Status = models.BatchStatusUpdated
+		default:
+			property.CreatedBy = &userID
+			property.CreatedAt = now
+			property.UpdatedAt = now
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(property))
+			results[i].Status = models.BatchStatusCreated
+		}
+
+		p := property
+		results[i].Property = &p
+		writeItems = append(writeItems, i)
+	}
+
+	res, err := pc.bulkWrite(ctx, writes, writeItems, results)
+	if err != nil {
+		return apperror.Internal("Failed to write properties")
+	}
+	if err := pc.markUnmatchedUpdates(ctx, res, writeItems, results, owners, models.BatchStatusConflict); err != nil {
+		return apperror.Internal("Failed to verify property updates")
+	}
+
+	pc.invalidateProperties(ctx, results)
+
+	return c.JSON(http.StatusOK, newBatchResponse(results))
+}
+
+// markUnmatchedUpdates finds the updated items whose write matched no
+// document, because the listing was deleted or changed owner after it was
+// read, and gives them status. Every update filters on the owner in owners,
+// so when the bulk result's match count falls short, a listing that no
+// longer exists under that owner is one this batch did not write.
+func (pc *PropertyController) markUnmatchedUpdates(ctx context.Context, res *mongo.BulkWriteResult, writeItems []int, results []models.BatchItemResult, owners map[string]*primitive.ObjectID, status string) error {
+	var updated []string
+	for _, i := range writeItems {
+		if results[i].Status == models.BatchStatusUpdated {
+			updated = append(updated, results[i].ExternalID)
+		}
+	}
+	if len(updated) == 0 || res == nil || int(res.MatchedCount) >= len(updated) {
+		return nil
+	}
+
+	stored, err := pc.findByIDs(ctx, updated)
+	if err != nil {
+		return err
+	}
+	for _, i := range writeItems {
+		if results[i].Status != models.BatchStatusUpdated {
+			continue
+		}
+		if property, ok := stored[results[i].ExternalID]; ok && sameOwner(property.CreatedBy, owners[results[i].ExternalID]) {
+			continue
+		}
+		results[i].Status = status
+		results[i].Error = "Property was changed or removed during the batch"
+		results[i].Property = nil
+	}
+	return nil
+}
+
+func sameOwner(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (pc *PropertyController) BatchPatchProperties(c echo.Context) error {
+	var req models.BatchPatchRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if len(req.Updates) == 0 {
+		return apperror.Validation("updates", "updates is required")
+	}
+	if len(req.Updates) > batchMaxItems() {
+		return apperror.New(http.StatusBadRequest, apperror.CodeBatchTooLarge, "Too many updates: maximum is "+strconv.Itoa(batchMaxItems()))
+	}
+
+	ctx := c.Request().Context()
+	results := make([]models.BatchItemResult, len(req.Updates))
+	ids := make([]string, 0, len(req.Updates))
+	seen := map[string]bool{}
+	for i, update := range req.Updates {
+		results[i].ExternalID = update.ExternalID
+		switch {
+		case !utils.IsValidExternalID(update.ExternalID):
+			results[i].Status = models.BatchStatusInvalid
+			results[i].Error = "Invalid property ID"
+		case seen[update.ExternalID]:
+			results[i].Status = models.BatchStatusInvalid
+			results[i].Error = "Duplicate externalId in batch"
+		default:
+			seen[update.ExternalID] = true
+			ids = append(ids, update.ExternalID)
+		}
+	}
+
+	existing, err := pc.findByIDs(ctx, ids)
+	if err != nil {
+		return apperror.Internal("Failed to fetch properties")
+	}
+
+	var writes []mongo.WriteModel
+	var writeItems []int
+	owners := map[string]*primitive.ObjectID{}
+	for i, update := range req.Updates {
+		if results[i].Status != "" {
+			continue
+		}
+
+		current, exists := existing[update.ExternalID]
+		if !exists {
+			results[i].Status = models.BatchStatusNotFound
+			results[i].Error = "Property not found"
+			continue
+		}
+		if !canUpdateProperty(c, current) {
+			results[i].Status = models.BatchStatusForbidden
+			results[i].Error = "You are not authorized to update this property"
+			continue
+		}
+
+		updateDoc, err := buildPropertyUpdate(update.Fields)
+		if err != nil {
+			results[i].Status = models.BatchStatusInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+
+		// As in BatchCreateProperties, the owner check above is repeated in
+		// the filter so a listing deleted or handed to someone else since is
+		// not written.
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": update.ExternalID, "createdBy": current.CreatedBy}).
+			SetUpdate(bson.M{"$set": updateDoc}))
+		owners[update.ExternalID] = current.CreatedBy
+		results[i].Status = models.BatchStatusUpdated
+		writeItems = append(writeItems, i)
+	}
+
+	res, err := pc.bulkWrite(ctx, writes, writeItems, results)
+	if err != nil {
+		return apperror.Internal("Failed to update properties")
+	}
+	if err := pc.markUnmatchedUpdates(ctx, res, writeItems, results, owners, models.BatchStatusFailed); err != nil {
+		return apperror.Internal("Failed to verify property updates")
+	}
+
+	var updatedIDs []string
+	for _, i := range writeItems {
+		if results[i].Status == models.BatchStatusUpdated {
+			updatedIDs = append(updatedIDs, results[i].ExternalID)
+		}
+	}
+	updated, err := pc.findByIDs(ctx, updatedIDs)
+	if err == nil {
+		for _, i := range writeItems {
+			if property, ok := updated[results[i].ExternalID]; ok {
+				results[i].Property = &property
+			}
+		}
+	}
+
+	pc.invalidateProperties(ctx, results)
+
+	return c.JSON(http.StatusOK, newBatchResponse(results))
+}
+
+// bulkWrite runs an unordered bulk write and maps per-operation failures back
+// onto the batch items at writeItems[i]. Only a failure that is not a
+// per-document write error is returned.
+func (pc *PropertyController) bulkWrite(ctx context.Context, writes []mongo.WriteModel, writeItems []int, results []models.BatchItemResult) (*mongo.BulkWriteResult, error) {
+	if len(writes) == 0 {
+		return nil, nil
+	}
+
+	res, err := pc.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err == nil {
+		return res, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Index < 0 || writeErr.Index >= len(writeItems) {
+			continue
+		}
+		i := writeItems[writeErr.Index]
+		results[i].Property = nil
+		if mongo.IsDuplicateKeyError(writeErr) {
+			results[i].Status = models.BatchStatusConflict
+			results[i].Error = "Property with this externalId already exists"
+		} else {
+			results[i].Status = models.BatchStatusFailed
+			results[i].Error = "Failed to write property"
+		}
+	}
+	return res, nil
+}
+
+func (pc *PropertyController) findByIDs(ctx context.Context, ids []string) (map[string]models.Property, error) {
+	byID := make(map[string]models.Property, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	cursor, err := pc.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var property models.Property
+		if err := cursor.Decode(&property); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable property document", "error", err)
+			continue
+		}
+		byID[property.ExternalID] = property
+	}
+	return byID, cursor.Err()
+}
+
+func (pc *PropertyController) invalidateProperties(ctx context.Context, results []models.BatchItemResult) {
+	var keys []string
+	for _, result := range results {
+		if result.Status == models.BatchStatusCreated || result.Status == models.BatchStatusUpdated {
+			keys = append(keys, "property:"+result.ExternalID)
+		}
+	}
+	if len(keys) > 0 {
+		utils.RedisClient.Del(ctx, keys...)
+		recommender.InvalidateSimilar(ctx)
+		utils.InvalidatePropertyLists(ctx)
+	}
+}
+
+func newBatchResponse(results []models.BatchItemResult) models.BatchResponse {
+	summary := map[string]int{}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	return models.BatchResponse{
+		Results: results,
+		Summary: summary,
+	}
+}
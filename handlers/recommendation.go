@@ -1,74 +1,152 @@
 package handlers
 
 import (
+	"PropertyListingSys/apperror"
 	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
 	"PropertyListingSys/models"
+	"PropertyListingSys/recommender"
 	"PropertyListingSys/utils"
-	"context"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RecommendationController struct {
-	collection     *mongo.Collection
-	userCollection *mongo.Collection
+	collection         *mongo.Collection
+	userCollection     *mongo.Collection
+	propertyCollection *mongo.Collection
+	engine             *recommender.Engine
 }
 
 func NewRecommendationController() *RecommendationController {
-	collectionName := os.Getenv("MONGODB_COLLECTION_RECOMMENDATIONS")
-	if collectionName == "" {
-		collectionName = "recommendations"
-	}
-	userCollectionName := os.Getenv("MONGODB_COLLECTION_USER")
-	if userCollectionName == "" {
-		userCollectionName = "user"
-	}
 	return &RecommendationController{
-		collection:     config.GetCollection(collectionName),
-		userCollection: config.GetCollection(userCollectionName),
+		collection:         config.GetCollection(config.App.Mongo.Collections.Recommendations),
+		userCollection:     config.GetCollection(config.App.Mongo.Collections.User),
+		propertyCollection: config.GetCollection(config.App.Mongo.Collections.Properties),
+		engine:             recommender.NewEngine(),
 	}
 }
 
+// CreateRecommendation addresses the recipient by handle, invite code or
+// email. An email that does not resolve to a discoverable user gets an
+// invite, so the response never reveals whether the address is registered.
 func (rc *RecommendationController) CreateRecommendation(c echo.Context) error {
 	recommenderID := c.Get("user_id").(primitive.ObjectID)
 	var req struct {
-		RecipientEmail string `json:"recipientEmail"`
-		PropertyID     string `json:"propertyId"`
+		models.UserLookup
+		PropertyID string `json:"propertyId"`
 	}
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if !utils.IsValidExternalID(req.PropertyID) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidPropertyID, "Invalid property ID")
+	}
+	if _, err := lookupFilter(req.UserLookup); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidUserLookup, "Exactly one of recipientEmail, recipientHandle or recipientInviteCode is required")
 	}
-	var recipient models.User
-	err := rc.userCollection.FindOne(context.Background(), bson.M{"email": req.RecipientEmail}).Decode(&recipient)
+	if err := allowUserLookup(c, recommenderID); err != nil {
+		return err
+	}
+
+	count, err := rc.propertyCollection.CountDocuments(c.Request().Context(), bson.M{"_id": req.PropertyID}, options.Count().SetLimit(1))
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Recipient not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to find recipient"})
+		return apperror.Internal("Failed to find property")
 	}
-	if !utils.IsValidExternalID(req.PropertyID) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid property ID"})
+	if count == 0 {
+		return apperror.New(http.StatusNotFound, apperror.CodePropertyNotFound, "Property not found")
+	}
+
+	recipient, err := findUserByLookup(c.Request().Context(), rc.userCollection, req.UserLookup)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return apperror.Internal("Failed to find recipient")
+		}
+		if req.Email != "" {
+			return rc.createInvite(c, recommenderID, utils.NormalizeEmail(req.Email), req.PropertyID)
+		}
+		return apperror.New(http.StatusNotFound, apperror.CodeRecipientNotFound, "Recipient not found")
 	}
 	recommendation := models.Recommendation{
 		ID:            primitive.NewObjectID(),
 		RecommenderID: recommenderID,
 		RecipientID:   recipient.ID,
 		PropertyID:    req.PropertyID,
+		Status:        models.RecommendationStatusDelivered,
 		CreatedAt:     time.Now(),
 	}
-	_, err = rc.collection.InsertOne(context.Background(), recommendation)
+	_, err = rc.collection.InsertOne(c.Request().Context(), recommendation)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create recommendation"})
+		return apperror.Internal("Failed to create recommendation")
 	}
 
 	cacheKey := "recommendations:" + recipient.ID.Hex()
-	utils.RedisClient.Del(context.Background(), cacheKey)
+	utils.RedisClient.Del(c.Request().Context(), cacheKey, recommender.CacheKey(recipient.ID))
+
+	return c.JSON(http.StatusCreated, recommendation)
+}
+
+// createInvite handles an email that did not resolve to a discoverable user.
+// If the address belongs to an undiscoverable account the recommendation is
+// delivered to it directly, but the sender gets the same pending response as
+// for an unregistered address.
+func (rc *RecommendationController) createInvite(c echo.Context, recommenderID primitive.ObjectID, email, propertyID string) error {
+	if !utils.IsValidEmail(email) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidEmail, "Invalid recipient email").WithDetails(apperror.FieldError{Field: "recipientEmail", Message: "must be a valid email address"})
+	}
+
+	ctx := c.Request().Context()
+	var existing models.User
+	err := rc.userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return apperror.Internal("Failed to find recipient")
+	}
+	registered := err == nil
+
+	recommendation := models.Recommendation{
+		ID:             primitive.NewObjectID(),
+		RecommenderID:  recommenderID,
+		RecipientEmail: email,
+		PropertyID:     propertyID,
+		Status:         models.RecommendationStatusPending,
+		CreatedAt:      time.Now(),
+	}
+	stored := recommendation
+	if registered {
+		stored.RecipientID = existing.ID
+		stored.Status = models.RecommendationStatusDelivered
+	}
+	_, err = rc.collection.InsertOne(ctx, stored)
+	if err != nil {
+		return apperror.Internal("Failed to create recommendation")
+	}
+
+	var sender models.User
+	recommenderName := "Someone"
+	if err := rc.userCollection.FindOne(ctx, bson.M{"_id": recommenderID}).Decode(&sender); err == nil && sender.Name != "" {
+		recommenderName = sender.Name
+	}
+
+	subject := recommenderName + " recommended a property to you"
+	body := recommenderName + " thinks you might like property " + propertyID + ".\n\n" +
+		"Create an account to see it: " + utils.SignupLink(email) + "\n"
+	if registered {
+		utils.RedisClient.Del(ctx, "recommendations:"+existing.ID.Hex(), recommender.CacheKey(existing.ID))
+		body = recommenderName + " thinks you might like property " + propertyID + ".\n\n" +
+			"Sign in to see it in your received recommendations.\n"
+	}
+	if err := utils.MailClient.Send(ctx, email, subject, body); err != nil {
+		return c.JSON(http.StatusAccepted, map[string]interface{}{
+			"recommendation": recommendation,
+			"warning":        "Recommendation saved but the invite email could not be sent",
+		})
+	}
 
 	return c.JSON(http.StatusCreated, recommendation)
 }
@@ -78,25 +156,38 @@ func (rc *RecommendationController) GetReceivedRecommendations(c echo.Context) e
 
 	var recommendations []models.Recommendation
 	cacheKey := "recommendations:" + userID.Hex()
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &recommendations); hit && err == nil {
 		return c.JSON(http.StatusOK, recommendations)
 	}
 
 	cursor, err := rc.collection.Find(ctx, bson.M{"recipientId": userID})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch recommendations"})
+		return apperror.Internal("Failed to fetch recommendations")
 	}
 	defer cursor.Close(ctx)
 	for cursor.Next(ctx) {
 		var rec models.Recommendation
 		if err := cursor.Decode(&rec); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable recommendation document", "error", err)
 			continue
 		}
 		recommendations = append(recommendations, rec)
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, recommendations, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
+	}
+
+	return c.JSON(http.StatusOK, recommendations)
+}
+
+func (rc *RecommendationController) GetPersonalizedRecommendations(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	recommendations, err := rc.engine.Get(c.Request().Context(), userID)
+	if err != nil {
+		return apperror.Internal("Failed to compute recommendations")
 	}
 
 	return c.JSON(http.StatusOK, recommendations)
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// startSession records a session for a successful login and returns a token
+// bound to it.
+func startSession(c echo.Context, user models.User, mfa bool) (string, error) {
+	session, err := utils.CreateSession(c.Request().Context(), user.ID, c.Request().UserAgent(), c.RealIP(), mfa)
+	if err != nil {
+		return "", err
+	}
+	if mfa {
+		return utils.GenerateMFAJWT(user.ID, user.Email, user.Role, session.ID.Hex())
+	}
+	return utils.GenerateJWT(user.ID, user.Email, user.Role, session.ID.Hex())
+}
+
+type SessionController struct {
+	collection *mongo.Collection
+}
+
+func NewSessionController() *SessionController {
+	return &SessionController{
+		collection: utils.SessionCollection(),
+	}
+}
+
+func (sc *SessionController) ListSessions(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+	currentID, _ := c.Get("session_id").(string)
+
+	ctx := c.Request().Context()
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}})
+	cursor, err := sc.collection.Find(ctx, bson.M{
+		"userId":    userID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return apperror.Internal("Failed to fetch sessions")
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	for cursor.Next(ctx) {
+		var session models.Session
+		if err := cursor.Decode(&session); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable session document", "error", err)
+			continue
+		}
+		session.Current = session.ID.Hex() == currentID
+		sessions = append(sessions, session)
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (sc *SessionController) RevokeSession(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidSessionID, "Invalid session ID")
+	}
+
+	revoked, err := utils.RevokeSessions(c.Request().Context(), userID, bson.M{"_id": sessionID})
+	if err != nil {
+		return apperror.Internal("Failed to revoke session")
+	}
+	if revoked == 0 {
+		return apperror.New(http.StatusNotFound, apperror.CodeSessionNotFound, "Session not found")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs the user out everywhere except the session making
+// the request.
+func (sc *SessionController) RevokeOtherSessions(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	filter := bson.M{}
+	if currentID, ok := c.Get("session_id").(string); ok {
+		if id, err := primitive.ObjectIDFromHex(currentID); err == nil {
+			filter["_id"] = bson.M{"$ne": id}
+		}
+	}
+
+	revoked, err := utils.RevokeSessions(c.Request().Context(), userID, filter)
+	if err != nil {
+		return apperror.Internal("Failed to revoke sessions")
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"revoked": revoked})
+}
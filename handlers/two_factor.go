@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/metrics"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	twoFactorChallengeTTL         = 5 * time.Minute
+	twoFactorChallengeMaxAttempts = 5
+	backupCodeCount               = 10
+)
+
+func twoFactorChallengeKey(token string) string {
+	return "2fa_challenge:" + utils.HashToken(token)
+}
+
+func (uc *UserController) issueTwoFactorChallenge(c echo.Context, user models.User) error {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return apperror.Internal("Failed to start two-factor login")
+	}
+
+	err = utils.RedisClient.Set(c.Request().Context(), twoFactorChallengeKey(token), user.ID.Hex(), twoFactorChallengeTTL).Err()
+	if err != nil {
+		return apperror.Internal("Failed to start two-factor login")
+	}
+
+	return c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(twoFactorChallengeTTL.Seconds()),
+	})
+}
+
+func (uc *UserController) VerifyTwoFactorLogin(c echo.Context) error {
+	var req models.TwoFactorLoginRequest
+	if err := c.Bind(&req); err != nil || req.ChallengeToken == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	ctx := c.Request().Context()
+	challengeKey := twoFactorChallengeKey(req.ChallengeToken)
+	userHex, err := utils.RedisClient.Get(ctx, challengeKey).Result()
+	if err != nil {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidChallenge, "Invalid or expired challenge")
+	}
+
+	attemptsKey := challengeKey + ":attempts"
+	attempts, err := utils.RedisClient.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		// Without the counter nothing limits guessing, so refuse the attempt.
+		return apperror.New(http.StatusServiceUnavailable, apperror.CodeServiceUnavailable, "Two-factor verification is unavailable; try again later").WithCause(err)
+	}
+	utils.RedisClient.Expire(ctx, attemptsKey, twoFactorChallengeTTL)
+	if attempts > twoFactorChallengeMaxAttempts {
+		utils.RedisClient.Del(ctx, challengeKey, attemptsKey)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeChallengeAttemptsExceeded, "Too many attempts; log in again")
+	}
+
+	userID, err := primitive.ObjectIDFromHex(userHex)
+	if err != nil {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidChallenge, "Invalid or expired challenge")
+	}
+
+	var user models.User
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil || !user.TOTPEnabled {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidChallenge, "Invalid or expired challenge")
+	}
+
+	if !uc.checkSecondFactor(ctx, user, req.Code, req.BackupCode) {
+		metrics.RecordLogin("totp", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	}
+
+	utils.RedisClient.Del(ctx, challengeKey, attemptsKey)
+
+	token, err := startSession(c, user, true)
+	if err != nil {
+		return apperror.Internal("Failed to generate token")
+	}
+	metrics.RecordLogin("totp", metrics.LoginSuccess)
+
+	user.Password = ""
+
+	return c.JSON(http.StatusOK, models.LoginResponse{
+		Token: token,
+		User:  user,
+	})
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused backup
+// code. Backup codes are pulled atomically so each works exactly once.
+func (uc *UserController) checkSecondFactor(ctx context.Context, user models.User, code, backupCode string) bool {
+	if code != "" {
+		return uc.checkTOTP(ctx, user.ID, user.TOTPSecret, code)
+	}
+	if backupCode == "" {
+		return false
+	}
+
+	hash := utils.HashToken(normalizeBackupCode(backupCode))
+	result, err := uc.collection.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "backup_codes": hash},
+		bson.M{"$pull": bson.M{"backup_codes": hash}},
+	)
+	return err == nil && result.ModifiedCount == 1
+}
+
+// checkTOTP validates code and claims its time step with SETNX, so of two
+// concurrent requests with the same code only one succeeds and the code
+// cannot be replayed within its validity window. If Redis is unavailable the
+// code is refused.
+func (uc *UserController) checkTOTP(ctx context.Context, userID primitive.ObjectID, secret, code string) bool {
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	usedKey := "2fa_used_step:" + userID.Hex() + ":" + strconv.FormatInt(step, 10)
+	claimed, err := utils.RedisClient.SetNX(ctx, usedKey, 1, 2*time.Minute).Result()
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to record TOTP step", "error", err)
+		return false
+	}
+	return claimed
+}
+
+func (uc *UserController) EnrollTwoFactor(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var user models.User
+	ctx := c.Request().Context()
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+	if user.TOTPEnabled {
+		return apperror.New(http.StatusConflict, apperror.CodeTwoFactorAlreadyEnabled, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return apperror.Internal("Failed to generate secret")
+	}
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"totp_pending_secret": secret}})
+	if err != nil {
+		return apperror.Internal("Failed to start enrollment")
+	}
+
+	return c.JSON(http.StatusOK, models.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+func (uc *UserController) ConfirmTwoFactor(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	var user models.User
+	ctx := c.Request().Context()
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+	if user.TOTPPendingSecret == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeTwoFactorEnrollmentNotFound, "No two-factor enrollment in progress")
+	}
+	if !uc.checkTOTP(ctx, user.ID, user.TOTPPendingSecret, req.Code) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	}
+
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return apperror.Internal("Failed to generate backup codes")
+	}
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"totp_enabled": true,
+			"totp_secret":  user.TOTPPendingSecret,
+			"backup_codes": hashes,
+			"updated_at":   time.Now(),
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	})
+	if err != nil {
+		return apperror.Internal("Failed to enable two-factor authentication")
+	}
+
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+
+	return c.JSON(http.StatusOK, models.BackupCodesResponse{BackupCodes: codes})
+}
+
+func (uc *UserController) DisableTwoFactor(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+	userRole := c.Get("user_role").(string)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	ctx := c.Request().Context()
+	required, err := utils.TwoFactorRequired(ctx, userRole)
+	if err != nil {
+		return apperror.New(http.StatusServiceUnavailable, apperror.CodeServiceUnavailable, "Security settings are unavailable; try again later").WithCause(err)
+	}
+	if required {
+		return apperror.New(http.StatusForbidden, apperror.CodeTwoFactorRequired, "Two-factor authentication is required for your role")
+	}
+
+	var user models.User
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+	if !user.TOTPEnabled {
+		return apperror.New(http.StatusBadRequest, apperror.CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+	}
+	if !uc.checkTOTP(ctx, user.ID, user.TOTPSecret, req.Code) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	}
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"totp_enabled": "", "totp_secret": "", "backup_codes": ""},
+	})
+	if err != nil {
+		return apperror.Internal("Failed to disable two-factor authentication")
+	}
+
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+func (uc *UserController) RegenerateBackupCodes(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var req models.TwoFactorCodeRequest
+	if err := c.Bind(&req); err != nil || req.Code == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	var user models.User
+	ctx := c.Request().Context()
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+	if !user.TOTPEnabled {
+		return apperror.New(http.StatusBadRequest, apperror.CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+	}
+	if !uc.checkTOTP(ctx, user.ID, user.TOTPSecret, req.Code) {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	}
+
+	codes, hashes, err := generateBackupCodes()
+	if err != nil {
+		return apperror.Internal("Failed to generate backup codes")
+	}
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"backup_codes": hashes}})
+	if err != nil {
+		return apperror.Internal("Failed to save backup codes")
+	}
+
+	return c.JSON(http.StatusOK, models.BackupCodesResponse{BackupCodes: codes})
+}
+
+func generateBackupCodes() ([]string, []string, error) {
+	codes := make([]string, backupCodeCount)
+	hashes := make([]string, backupCodeCount)
+	for i := range codes {
+		raw, err := utils.GenerateToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = utils.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeBackupCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
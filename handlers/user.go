@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"PropertyListingSys/apperror"
 	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/metrics"
 	"PropertyListingSys/models"
+	"PropertyListingSys/privacy"
 	"PropertyListingSys/utils"
 	"context"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -16,40 +21,38 @@ import (
 )
 
 type UserController struct {
-	collection *mongo.Collection
+	collection               *mongo.Collection
+	recommendationCollection *mongo.Collection
+	auditCollection          *mongo.Collection
+	privacy                  *privacy.Service
 }
 
 func NewUserController() *UserController {
-	collectionName := os.Getenv("MONGODB_COLLECTION_USER")
-	if collectionName == "" {
-		collectionName = "user"
-	}
+	collections := config.App.Mongo.Collections
 	return &UserController{
-		collection: config.GetCollection(collectionName),
+		collection:               config.GetCollection(collections.User),
+		recommendationCollection: config.GetCollection(collections.Recommendations),
+		auditCollection:          config.GetCollection(collections.Audit),
+		privacy:                  privacy.NewService(),
 	}
 }
 
 func (uc *UserController) Register(c echo.Context) error {
 	var req models.RegisterRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
 	}
+	req.Email = utils.NormalizeEmail(req.Email)
 
 	var existingUser models.User
-	err := uc.collection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&existingUser)
+	err := uc.collection.FindOne(c.Request().Context(), bson.M{"email": req.Email}).Decode(&existingUser)
 	if err == nil {
-		return c.JSON(http.StatusConflict, map[string]string{
-			"error": "User with this email already exists",
-		})
+		return apperror.New(http.StatusConflict, apperror.CodeEmailTaken, "User with this email already exists")
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to hash password",
-		})
+		return apperror.Internal("Failed to hash password")
 	}
 
 	user := models.User{
@@ -58,27 +61,25 @@ func (uc *UserController) Register(c echo.Context) error {
 		Password:  hashedPassword,
 		Name:      req.Name,
 		Phone:     req.Phone,
-		Role:      "user",
+		Role:      utils.RoleUser,
 		IsActive:  true,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	_, err = uc.collection.InsertOne(context.Background(), user)
+	_, err = uc.collection.InsertOne(c.Request().Context(), user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create user",
-		})
+		return apperror.Internal("Failed to create user")
 	}
 
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	utils.RedisClient.Del(ctx, "users:all")
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	uc.attachPendingRecommendations(ctx, user)
+
+	token, err := startSession(c, user, false)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate token",
-		})
+		return apperror.Internal("Failed to generate token")
 	}
 
 	user.Password = ""
@@ -89,41 +90,83 @@ func (uc *UserController) Register(c echo.Context) error {
 	})
 }
 
+func (uc *UserController) attachPendingRecommendations(ctx context.Context, user models.User) {
+	result, err := uc.recommendationCollection.UpdateMany(
+		ctx,
+		bson.M{"recipientEmail": utils.NormalizeEmail(user.Email), "status": models.RecommendationStatusPending},
+		bson.M{"$set": bson.M{"recipientId": user.ID, "status": models.RecommendationStatusDelivered}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return
+	}
+	utils.RedisClient.Del(ctx, "recommendations:"+user.ID.Hex())
+}
+
 func (uc *UserController) Login(c echo.Context) error {
 	var req models.LoginRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	ctx := c.Request().Context()
+	ip := c.RealIP()
+	if wait := utils.LoginRetryAfter(ctx, req.Email, ip); wait > 0 {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return apperror.New(http.StatusTooManyRequests, apperror.CodeTooManyLoginAttempts, "Too many failed login attempts; try again later")
 	}
 
 	var user models.User
-	err := uc.collection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&user)
+	err := uc.collection.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid email or password",
-		})
+		utils.CheckPasswordAgainstDummy(req.Password)
+		utils.RecordLoginFailure(ctx, req.Email, ip)
+		metrics.RecordLogin("password", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidCredentials, "Invalid email or password")
 	}
 
-	if !user.IsActive {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Account is deactivated",
-		})
+	if user.Password == "" {
+		utils.CheckPasswordAgainstDummy(req.Password)
+		utils.RecordLoginFailure(ctx, req.Email, ip)
+		metrics.RecordLogin("password", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidCredentials, "Invalid email or password")
 	}
 
 	err = utils.CheckPassword(user.Password, req.Password)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid email or password",
-		})
+		if locked := utils.RecordLoginFailure(ctx, req.Email, ip); locked {
+			// Sent in the background so the response does not wait on SMTP,
+			// and its timing does not reveal that the account just locked.
+			mailCtx := context.WithoutCancel(ctx)
+			go func() {
+				if err := sendUnlockEmail(mailCtx, user); err != nil {
+					logging.FromContext(mailCtx).Error("Failed to send account unlock email", "user_id", user.ID.Hex(), "error", err)
+				}
+			}()
+		}
+		metrics.RecordLogin("password", metrics.LoginFailure)
+		return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidCredentials, "Invalid email or password")
+	}
+
+	utils.ResetLoginFailures(ctx, req.Email)
+
+	if !user.IsActive {
+		return apperror.New(http.StatusUnauthorized, apperror.CodeAccountDeactivated, "Account is deactivated")
+	}
+
+	if user.PasswordResetRequired {
+		return apperror.New(http.StatusForbidden, apperror.CodePasswordResetRequired, "Password reset required; check your email for a reset link")
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, user.Role)
+	if user.TOTPEnabled {
+		return uc.issueTwoFactorChallenge(c, user)
+	}
+
+	token, err := startSession(c, user, false)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate token",
-		})
+		return apperror.Internal("Failed to generate token")
 	}
+	metrics.RecordLogin("password", metrics.LoginSuccess)
 
 	user.Password = ""
 
@@ -133,12 +176,54 @@ func (uc *UserController) Login(c echo.Context) error {
 	})
 }
 
+func unlockKey(token string) string {
+	return "login_unlock:" + utils.HashToken(token)
+}
+
+func sendUnlockEmail(ctx context.Context, user models.User) error {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	if err := utils.RedisClient.Set(ctx, unlockKey(token), user.Email, utils.LoginLockoutDuration()).Err(); err != nil {
+		return err
+	}
+
+	unlockURL := config.App.Links.AccountUnlockURL
+
+	body := "Your account was temporarily locked after repeated failed login attempts.\n\n" +
+		"If this was you, unlock it now: " + unlockURL + "?token=" + token + "\n" +
+		"If it was not, consider changing your password.\n"
+	return utils.MailClient.Send(ctx, user.Email, "Your account has been locked", body)
+}
+
+func (uc *UserController) UnlockAccount(c echo.Context) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	ctx := c.Request().Context()
+	email, err := utils.RedisClient.GetDel(ctx, unlockKey(req.Token)).Result()
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidUnlockToken, "Invalid or expired unlock token")
+	}
+
+	utils.ResetLoginFailures(ctx, utils.NormalizeEmail(email))
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Account unlocked successfully",
+	})
+}
+
 func (uc *UserController) GetProfile(c echo.Context) error {
 	userID := c.Get("user_id").(primitive.ObjectID)
 
 	var user models.User
 	cacheKey := "user:profile:" + userID.Hex()
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &user); hit && err == nil {
 		user.Password = ""
 		return c.JSON(http.StatusOK, user)
@@ -146,12 +231,11 @@ func (uc *UserController) GetProfile(c echo.Context) error {
 
 	err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "User not found",
-		})
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, user, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
 	}
 
 	user.Password = ""
@@ -164,9 +248,7 @@ func (uc *UserController) UpdateProfile(c echo.Context) error {
 
 	var req models.UpdateUserRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request body",
-		})
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
 	}
 
 	updateDoc := bson.M{
@@ -181,25 +263,21 @@ func (uc *UserController) UpdateProfile(c echo.Context) error {
 	}
 
 	_, err := uc.collection.UpdateOne(
-		context.Background(),
+		c.Request().Context(),
 		bson.M{"_id": userID},
 		bson.M{"$set": updateDoc},
 	)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update user",
-		})
+		return apperror.Internal("Failed to update user")
 	}
 
 	var user models.User
-	err = uc.collection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+	err = uc.collection.FindOne(c.Request().Context(), bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch updated user",
-		})
+		return apperror.Internal("Failed to fetch updated user")
 	}
 
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	cacheKeyProfile := "user:profile:" + userID.Hex()
 	cacheKeyEmail := "user:email:" + user.Email
 	utils.RedisClient.Del(ctx, cacheKeyProfile, cacheKeyEmail, "users:all")
@@ -209,45 +287,119 @@ func (uc *UserController) UpdateProfile(c echo.Context) error {
 	return c.JSON(http.StatusOK, user)
 }
 
+// DeleteAccount schedules the account for erasure after the grace period.
+// The user can still log in and cancel until then; with no grace period the
+// account is purged immediately.
 func (uc *UserController) DeleteAccount(c echo.Context) error {
 	userID := c.Get("user_id").(primitive.ObjectID)
+	if _, impersonated := c.Get("impersonator_id").(primitive.ObjectID); impersonated {
+		return apperror.New(http.StatusForbidden, apperror.CodeImpersonationNotAllowed, "Accounts cannot be deleted while impersonating")
+	}
 
 	var user models.User
-	err := uc.collection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+	ctx := c.Request().Context()
+	err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "User not found",
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+
+	if user.DeletionScheduledAt != nil {
+		return c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+			Message:             "Account deletion is already scheduled",
+			DeletionScheduledAt: *user.DeletionScheduledAt,
 		})
 	}
 
-	_, err = uc.collection.DeleteOne(context.Background(), bson.M{"_id": userID})
+	now := time.Now()
+	scheduledAt := now.Add(privacy.GracePeriod())
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"deletion_requested_at": now,
+		"deletion_scheduled_at": scheduledAt,
+		"updated_at":            now,
+	}})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to delete user",
+		return apperror.Internal("Failed to schedule account deletion")
+	}
+
+	if !scheduledAt.After(now) {
+		user.DeletionRequestedAt = &now
+		// A purge interrupted by the client disconnecting is picked up by
+		// the job, but running it to completion here answers the request.
+		if _, err := uc.privacy.Purge(context.WithoutCancel(ctx), user); err != nil {
+			return apperror.Internal("Failed to delete user")
+		}
+		return c.JSON(http.StatusOK, map[string]string{
+			"message": "Account deleted successfully",
 		})
 	}
 
-	ctx := context.Background()
-	cacheKeyProfile := "user:profile:" + userID.Hex()
-	cacheKeyEmail := "user:email:" + user.Email
-	utils.RedisClient.Del(ctx, cacheKeyProfile, cacheKeyEmail, "users:all")
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+	recordAudit(uc.auditCollection, c, "user.deletion_requested", userID, map[string]interface{}{
+		"scheduledAt": scheduledAt,
+	})
+
+	return c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+		Message:             "Account scheduled for deletion; log in and cancel before then to keep it",
+		DeletionScheduledAt: scheduledAt,
+	})
+}
+
+func (uc *UserController) CancelAccountDeletion(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	ctx := c.Request().Context()
+	result, err := uc.collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "deletion_scheduled_at": bson.M{"$exists": true}, "deletion_started_at": bson.M{"$exists": false}},
+		bson.M{
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$unset": bson.M{"deletion_requested_at": "", "deletion_scheduled_at": ""},
+		},
+	)
+	if err != nil {
+		return apperror.Internal("Failed to cancel account deletion")
+	}
+	if result.ModifiedCount == 0 {
+		started := uc.collection.FindOne(ctx, bson.M{"_id": userID, "deletion_started_at": bson.M{"$exists": true}}).Err()
+		if started == nil {
+			return apperror.New(http.StatusConflict, apperror.CodeDeletionInProgress, "Account deletion is already in progress")
+		}
+		return apperror.New(http.StatusNotFound, apperror.CodeDeletionNotScheduled, "No account deletion is scheduled")
+	}
+
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+	recordAudit(uc.auditCollection, c, "user.deletion_cancelled", userID, nil)
 
 	return c.JSON(http.StatusOK, map[string]string{
-		"message": "Account deleted successfully",
+		"message": "Account deletion cancelled",
 	})
 }
 
-func (uc *UserController) GetAllUsers(c echo.Context) error {
-	userRole := c.Get("user_role").(string)
-	if userRole != "admin" {
-		return c.JSON(http.StatusForbidden, map[string]string{
-			"error": "Access denied",
-		})
+func (uc *UserController) ExportData(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var user models.User
+	ctx := c.Request().Context()
+	if err := uc.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
 	}
 
+	archive, err := uc.privacy.Export(ctx, user)
+	if err != nil {
+		return apperror.Internal("Failed to export data")
+	}
+
+	recordAudit(uc.auditCollection, c, "user.data_exported", userID, nil)
+
+	filename := "export-" + userID.Hex() + "-" + time.Now().Format("20060102") + ".zip"
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.Blob(http.StatusOK, "application/zip", archive)
+}
+
+func (uc *UserController) GetAllUsers(c echo.Context) error {
 	var users []models.User
 	cacheKey := "users:all"
-	ctx := context.Background()
+	ctx := c.Request().Context()
 	if hit, err := utils.GetCached(ctx, cacheKey, &users); hit && err == nil {
 		for i := range users {
 			users[i].Password = ""
@@ -257,15 +409,14 @@ func (uc *UserController) GetAllUsers(c echo.Context) error {
 
 	cursor, err := uc.collection.Find(ctx, bson.M{})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to fetch users",
-		})
+		return apperror.Internal("Failed to fetch users")
 	}
 	defer cursor.Close(ctx)
 
 	for cursor.Next(ctx) {
 		var user models.User
 		if err := cursor.Decode(&user); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable user document", "error", err)
 			continue
 		}
 		user.Password = ""
@@ -273,34 +424,184 @@ func (uc *UserController) GetAllUsers(c echo.Context) error {
 	}
 
 	if err := utils.SetCached(ctx, cacheKey, users, 30*time.Second); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
 	}
 
 	return c.JSON(http.StatusOK, users)
 }
 
-func (uc *UserController) SearchUserByEmail(c echo.Context) error {
-	email := c.QueryParam("email")
-	if email == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Email is required"})
-	}
+// SearchUser resolves another user by handle, invite code or, if they have
+// opted in to discovery, email.
+func (uc *UserController) SearchUser(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
 
-	var user models.User
-	cacheKey := "user:email:" + email
-	ctx := context.Background()
-	if hit, err := utils.GetCached(ctx, cacheKey, &user); hit && err == nil {
-		return c.JSON(http.StatusOK, map[string]string{"id": user.ID.Hex(), "name": user.Name})
+	var lookup models.UserLookup
+	if err := c.Bind(&lookup); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidQueryParameter, "Invalid query")
+	}
+	if _, err := lookupFilter(lookup); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidUserLookup, "Exactly one of email, handle or invite_code is required")
+	}
+	if err := allowUserLookup(c, userID); err != nil {
+		return err
 	}
 
-	err := uc.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	user, err := findUserByLookup(c.Request().Context(), uc.collection, lookup)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+			return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search user"})
+		return apperror.Internal("Failed to search user")
 	}
 
-	if err := utils.SetCached(ctx, cacheKey, user, 30*time.Second); err != nil {
+	return c.JSON(http.StatusOK, map[string]string{"id": user.ID.Hex(), "name": user.Name, "handle": user.Handle})
+}
+
+func (uc *UserController) GetPrivacySettings(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var user models.User
+	if err := uc.collection.FindOne(c.Request().Context(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		return apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User not found")
+	}
+
+	return c.JSON(http.StatusOK, models.PrivacySettings{
+		DiscoverableByEmail: user.DiscoverableByEmail,
+		Handle:              user.Handle,
+		InviteCode:          user.InviteCode,
+	})
+}
+
+func (uc *UserController) UpdatePrivacySettings(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	var req models.UpdatePrivacyRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	ctx := c.Request().Context()
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	if req.DiscoverableByEmail != nil {
+		if *req.DiscoverableByEmail {
+			set["discoverable_by_email"] = true
+		} else {
+			unset["discoverable_by_email"] = ""
+		}
+	}
+	if req.Handle != nil {
+		handle := utils.NormalizeHandle(*req.Handle)
+		switch {
+		case handle == "":
+			unset["handle"] = ""
+		case !utils.IsValidHandle(handle):
+			return apperror.New(http.StatusBadRequest, apperror.CodeInvalidHandle, "Handle must be 3-30 characters of letters, digits, '_' or '.'").WithDetails(apperror.FieldError{Field: "handle", Message: "must be 3-30 characters of letters, digits, '_' or '.'"})
+		default:
+			err := uc.collection.FindOne(ctx, bson.M{"handle": handle, "_id": bson.M{"$ne": userID}}).Err()
+			if err == nil {
+				return apperror.New(http.StatusConflict, apperror.CodeHandleTaken, "Handle is already taken")
+			}
+			if err != mongo.ErrNoDocuments {
+				return apperror.Internal("Failed to check handle")
+			}
+			set["handle"] = handle
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if _, err := uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+		// The check above can race with another user claiming the handle;
+		// the unique index settles it.
+		if mongo.IsDuplicateKeyError(err) {
+			return apperror.New(http.StatusConflict, apperror.CodeHandleTaken, "Handle is already taken")
+		}
+		return apperror.Internal("Failed to update privacy settings")
+	}
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
+
+	return uc.GetPrivacySettings(c)
+}
+
+// RegenerateInviteCode issues a new invite code; the previous one stops
+// resolving immediately.
+func (uc *UserController) RegenerateInviteCode(c echo.Context) error {
+	userID := c.Get("user_id").(primitive.ObjectID)
+
+	code, err := utils.GenerateInviteCode()
+	if err != nil {
+		return apperror.Internal("Failed to generate invite code")
+	}
+
+	_, err = uc.collection.UpdateOne(c.Request().Context(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"invite_code": code}})
+	if err != nil {
+		return apperror.Internal("Failed to save invite code")
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"invite_code": code})
+}
+
+func passwordResetKey(token string) string {
+	return "password_reset:" + utils.HashToken(token)
+}
+
+func sendPasswordResetEmail(ctx context.Context, user models.User) error {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return err
+	}
+	if err := utils.RedisClient.Set(ctx, passwordResetKey(token), user.ID.Hex(), time.Hour).Err(); err != nil {
+		return err
+	}
+
+	resetURL := config.App.Links.PasswordResetURL
+
+	body := "A password reset was requested for your account.\n\n" +
+		"Reset your password within one hour: " + resetURL + "?token=" + token + "\n"
+	return utils.MailClient.Send(ctx, user.Email, "Reset your password", body)
+}
+
+func (uc *UserController) ResetPassword(c echo.Context) error {
+	var req models.ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if req.Token == "" || len(req.Password) < 6 {
+		return apperror.New(http.StatusBadRequest, apperror.CodeValidationFailed, "Token and a password of at least 6 characters are required")
+	}
+
+	ctx := c.Request().Context()
+	userHex, err := utils.RedisClient.GetDel(ctx, passwordResetKey(req.Token)).Result()
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidResetToken, "Invalid or expired reset token")
+	}
+	userID, err := primitive.ObjectIDFromHex(userHex)
+	if err != nil {
+		return apperror.New(http.StatusBadRequest, apperror.CodeInvalidResetToken, "Invalid or expired reset token")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return apperror.Internal("Failed to hash password")
+	}
+
+	_, err = uc.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set":   bson.M{"password": hashedPassword, "email_verified": true, "updated_at": time.Now()},
+		"$unset": bson.M{"password_reset_required": ""},
+	})
+	if err != nil {
+		return apperror.Internal("Failed to reset password")
+	}
+
+	if err := utils.RevokeUserTokens(ctx, userID); err != nil {
+		logging.FromContext(ctx).Error("Failed to revoke tokens after password reset", "user_id", userID.Hex(), "error", err)
 	}
+	utils.RedisClient.Del(ctx, "user:profile:"+userID.Hex())
 
-	return c.JSON(http.StatusOK, map[string]string{"id": user.ID.Hex(), "name": user.Name})
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password reset successfully",
+	})
 }
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errInvalidUserLookup = errors.New("exactly one of email, handle or invite code is required")
+
+// lookupFilter builds the query for a user lookup. Email only matches users
+// who opted in to discovery, so an undiscoverable address is
+// indistinguishable from an unregistered one.
+func lookupFilter(lookup models.UserLookup) (bson.M, error) {
+	email := utils.NormalizeEmail(lookup.Email)
+	handle := utils.NormalizeHandle(lookup.Handle)
+	code := strings.TrimSpace(lookup.InviteCode)
+
+	set := 0
+	for _, v := range []string{email, handle, code} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errInvalidUserLookup
+	}
+
+	switch {
+	case email != "":
+		return bson.M{"email": email, "discoverable_by_email": true}, nil
+	case handle != "":
+		return bson.M{"handle": handle}, nil
+	default:
+		return bson.M{"invite_code": code}, nil
+	}
+}
+
+func findUserByLookup(ctx context.Context, users *mongo.Collection, lookup models.UserLookup) (models.User, error) {
+	var user models.User
+	filter, err := lookupFilter(lookup)
+	if err != nil {
+		return user, err
+	}
+	err = users.FindOne(ctx, filter).Decode(&user)
+	return user, err
+}
+
+// allowUserLookup enforces the per-user lookup quota, returning a 429 error
+// once it is spent.
+func allowUserLookup(c echo.Context, userID primitive.ObjectID) error {
+	wait := utils.UserLookupRetryAfter(c.Request().Context(), userID)
+	if wait <= 0 {
+		return nil
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return apperror.New(http.StatusTooManyRequests, apperror.CodeTooManyLookups, "Too many user lookups; try again later")
+}
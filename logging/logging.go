@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"PropertyListingSys/config"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type loggerKey struct{}
+
+// Init installs the process-wide slog logger. Output from the standard log
+// package is routed through it as well.
+func Init(cfg config.LogConfig) {
+	slog.SetDefault(slog.New(NewHandler(os.Stdout, cfg)))
+}
+
+func NewHandler(w io.Writer, cfg config.LogConfig) slog.Handler {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(cfg.Format, "text") {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger stored in ctx, which carries
+// the request ID, route and user, or the default logger outside a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// For returns the logger for the request being handled by c.
+func For(c echo.Context) *slog.Logger {
+	return FromContext(c.Request().Context())
+}
+
+// With adds attributes to the request's logger for the rest of the request.
+func With(c echo.Context, args ...any) {
+	req := c.Request()
+	c.SetRequest(req.WithContext(NewContext(req.Context(), FromContext(req.Context()).With(args...))))
+}
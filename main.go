@@ -2,38 +2,196 @@ package main
 
 import (
 	"PropertyListingSys/config"
+	"PropertyListingSys/handlers"
+	"PropertyListingSys/logging"
+	appMiddleware "PropertyListingSys/middleware"
+	"PropertyListingSys/privacy"
+	"PropertyListingSys/recommender"
 	"PropertyListingSys/routes"
+	"PropertyListingSys/tracing"
 	"PropertyListingSys/utils"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	config.App = cfg
+
+	if *printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Failed to print configuration", err)
+		}
+		return
+	}
+
+	logging.Init(cfg.Log)
+
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		fatal("Failed to set up tracing", err)
 	}
 
 	config.ConnectDB()
 
+	usersCtx, cancelUsers := context.WithTimeout(context.Background(), 30*time.Second)
+	err = utils.EnsureUserIndexes(usersCtx)
+	if err == nil {
+		err = utils.NormalizeStoredEmails(usersCtx)
+	}
+	cancelUsers()
+	if err != nil {
+		fatal("Failed to prepare the users collection", err)
+	}
+
 	utils.InitRedis()
 
+	utils.InitMailer()
+
+	if err := utils.InitJWTKeys(); err != nil {
+		fatal("Failed to load JWT keys", err)
+	}
+
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	e.IPExtractor = ipExtractor(config.App.Server.TrustedProxies)
 
-	e.Use(middleware.Logger())
-	e.Use(middleware.Recover())
-	e.Use(middleware.CORS())
+	e.Use(otelecho.Middleware(config.App.Tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/metrics", "/livez", "/readyz", "/health":
+			return true
+		}
+		return false
+	})))
+	e.Use(appMiddleware.RequestLogger())
+	e.Use(appMiddleware.MetricsMiddleware())
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			logging.For(c).Error("Recovered from panic", "error", err, "stack", string(stack))
+			return err
+		},
+	}))
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		ExposeHeaders: []string{
+			echo.HeaderXRequestID,
+			echo.HeaderRetryAfter,
+			"RateLimit-Limit",
+			"RateLimit-Remaining",
+			"RateLimit-Reset",
+			"RateLimit-Policy",
+		},
+	}))
 
 	routes.RegisterRoutes(e)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	serverCfg := config.App.Server
+	e.Server.ReadTimeout = time.Duration(serverCfg.ReadTimeoutSeconds) * time.Second
+	e.Server.WriteTimeout = time.Duration(serverCfg.WriteTimeoutSeconds) * time.Second
+	e.Server.IdleTimeout = time.Duration(serverCfg.IdleTimeoutSeconds) * time.Second
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
 	}
+	startWorker(func(ctx context.Context) { recommender.NewEngine().Run(ctx, recommender.Interval()) })
+	startWorker(func(ctx context.Context) { privacy.NewService().Run(ctx, privacy.Interval()) })
+
+	port := strconv.Itoa(serverCfg.Port)
+
+	go func() {
+		slog.Info("Server starting", "port", port)
+		if err := e.Start(":" + port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed", err)
+		}
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	<-signals.Done()
+	stopSignals()
+	slog.Info("Shutting down: draining in-flight requests")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(serverCfg.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests first, so
+	// they can still use MongoDB and Redis while they finish.
+	if err := e.Shutdown(ctx); err != nil {
+		slog.Warn("Shutdown: server did not drain in time", "error", err)
+	}
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Shutdown: background workers did not stop in time")
+	}
+
+	if err := config.DisconnectDB(ctx); err != nil {
+		slog.Error("Shutdown: failed to disconnect MongoDB", "error", err)
+	}
+	if err := utils.CloseRedis(); err != nil {
+		slog.Error("Shutdown: failed to close Redis", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Shutdown: failed to flush traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// ipExtractor decides where c.RealIP comes from. Forwarding headers are only
+// read when they were added by one of the trusted proxies; otherwise any
+// client could pick its own address and dodge per-IP limits.
+func ipExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		_, ipRange, _ := net.ParseCIDR(cidr)
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
 
-	log.Printf("Server starting on port %s", port)
-	e.Logger.Fatal(e.Start(":" + port))
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collectors are registered with the default Prometheus registry, which
+// already includes the Go runtime and process collectors.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Redis cache lookups by key prefix and result (hit, miss or error).",
+	}, []string{"prefix", "result"})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongodb_command_duration_seconds",
+		Help:    "MongoDB command latency by collection, command and outcome.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"collection", "command", "status"})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by method (password, totp, oidc) and result (success, failure).",
+	}, []string{"method", "result"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+)
+
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+func ObserveHTTPRequest(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// cachePrefixes are the key families reported by ObserveCacheLookup, most
+// specific first. Any other key is counted as "other", so IDs, hashes or
+// generation numbers in keys never become label values.
+var cachePrefixes = []string{
+	"user:profile:",
+	"users:",
+	"property:",
+	"properties:",
+	"similar:",
+	"favorites:",
+	"recommendations:",
+	"recommended:",
+	"apikey:",
+}
+
+// ObserveCacheLookup counts a cache lookup under its key family, e.g.
+// "user:profile".
+func ObserveCacheLookup(key, result string) {
+	prefix := "other"
+	for _, p := range cachePrefixes {
+		if strings.HasPrefix(key, p) {
+			prefix = strings.TrimSuffix(p, ":")
+			break
+		}
+	}
+	cacheRequests.WithLabelValues(prefix, result).Inc()
+}
+
+func ObserveMongoCommand(collection, command string, failed bool, elapsed time.Duration) {
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+	mongoDuration.WithLabelValues(collection, command, status).Observe(elapsed.Seconds())
+}
+
+func RecordLogin(method, result string) {
+	logins.WithLabelValues(method, result).Inc()
+}
+
+func RecordRateLimited(policy string) {
+	rateLimited.WithLabelValues(policy).Inc()
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// NewMongoMonitor returns a command monitor that records the latency of
+// every MongoDB command. The collection name is only present on the started
+// event, so it is kept per request ID until the command finishes.
+func NewMongoMonitor() *event.CommandMonitor {
+	var collections sync.Map
+
+	finished := func(evt event.CommandFinishedEvent, failed bool) {
+		collection := ""
+		if name, ok := collections.LoadAndDelete(evt.RequestID); ok {
+			collection = name.(string)
+		}
+		ObserveMongoCommand(collection, evt.CommandName, failed, evt.Duration)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			field := evt.CommandName
+			if field == "getMore" {
+				field = "collection"
+			}
+			if name, ok := evt.Command.Lookup(field).StringValueOK(); ok {
+				collections.Store(evt.RequestID, name)
+			}
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finished(evt.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finished(evt.CommandFinishedEvent, true)
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// apiKeyRoutes maps "METHOD /path" to the scopes that let an API key call
+// that route. It is filled while routes are registered and only read after.
+var apiKeyRoutes = map[string][]string{}
+
+// AllowAPIKey lets API keys call route if they hold any of scopes. Keys are
+// refused on every route not declared this way, so a new endpoint stays
+// closed to them until it is given a scope.
+func AllowAPIKey(route *echo.Route, scopes ...string) {
+	apiKeyRoutes[route.Method+" "+route.Path] = scopes
+}
+
+func checkAPIKeyRoute(c echo.Context, keyScopes []string) error {
+	scopes, declared := apiKeyRoutes[c.Request().Method+" "+c.Path()]
+	if !declared {
+		return apperror.New(http.StatusForbidden, apperror.CodeAPIKeyNotAllowed, "API keys cannot be used on this endpoint")
+	}
+	for _, scope := range scopes {
+		if utils.HasScope(keyScopes, scope) {
+			return nil
+		}
+	}
+	return apperror.New(http.StatusForbidden, apperror.CodeInsufficientScope, "API key is missing the required scope")
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/tracing"
+	"PropertyListingSys/utils"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuthMiddleware accepts either an API key, via the X-API-Key header or an
+// "ApiKey <key>" Authorization header, or falls back to JWTMiddleware. API
+// keys only reach routes declared with AllowAPIKey.
+func AuthMiddleware() echo.MiddlewareFunc {
+	jwtMiddleware := JWTMiddleware()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtMiddleware(next)
+		return func(c echo.Context) error {
+			key := apiKeyFromRequest(c)
+			if key == "" {
+				return withJWT(c)
+			}
+
+			identity, err := utils.ResolveAPIKey(c.Request().Context(), key)
+			if err != nil {
+				return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidAPIKey, "Invalid API key")
+			}
+			if err := checkAPIKeyRoute(c, identity.Scopes); err != nil {
+				return err
+			}
+
+			c.Set("user_id", identity.UserID)
+			c.Set("user_email", identity.Email)
+			c.Set("user_role", identity.Role)
+			c.Set("mfa", identity.MFA)
+			c.Set("api_key_id", identity.KeyID)
+			c.Set("api_key_scopes", identity.Scopes)
+			annotateUser(c)
+
+			return next(c)
+		}
+	}
+}
+
+// annotateUser records the authenticated caller on the request's trace span
+// and logger.
+func annotateUser(c echo.Context) {
+	tracing.SetUser(c)
+
+	var attrs []any
+	if userID, ok := c.Get("user_id").(primitive.ObjectID); ok {
+		attrs = append(attrs, "user_id", userID.Hex())
+	}
+	if impersonatorID, ok := c.Get("impersonator_id").(primitive.ObjectID); ok {
+		attrs = append(attrs, "impersonator_id", impersonatorID.Hex())
+	}
+	if keyID, ok := c.Get("api_key_id").(primitive.ObjectID); ok {
+		attrs = append(attrs, "api_key_id", keyID.Hex())
+	}
+	logging.With(c, attrs...)
+}
+
+func apiKeyFromRequest(c echo.Context) string {
+	if key := c.Request().Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	authHeader := c.Request().Header.Get("Authorization")
+	if key, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"PropertyListingSys/apperror"
 	"PropertyListingSys/utils"
 	"net/http"
 	"strings"
@@ -13,31 +14,78 @@ func JWTMiddleware() echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Authorization header is required",
-				})
+				return apperror.New(http.StatusUnauthorized, apperror.CodeMissingAuthorization, "Authorization header is required")
 			}
 
 			tokenParts := strings.Split(authHeader, " ")
 			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid authorization header format",
-				})
+				return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidAuthorizationHeader, "Invalid authorization header format")
 			}
 
 			tokenString := tokenParts[1]
 			claims, err := utils.ValidateJWT(tokenString)
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "Invalid token",
-				})
+				return apperror.New(http.StatusUnauthorized, apperror.CodeInvalidToken, "Invalid token")
 			}
 
-			c.Set("user_id", claims.UserID)
-			c.Set("user_email", claims.Email)
-			c.Set("user_role", claims.Role)
+			if utils.IsTokenRevoked(c.Request().Context(), claims) {
+				return apperror.New(http.StatusUnauthorized, apperror.CodeTokenRevoked, "Token has been revoked")
+			}
+
+			if claims.SessionID != "" {
+				active, err := utils.IsSessionActive(c.Request().Context(), claims.SessionID)
+				if err != nil {
+					return apperror.New(http.StatusServiceUnavailable, apperror.CodeServiceUnavailable, "Unable to verify session; try again later").WithCause(err)
+				}
+				if !active {
+					return apperror.New(http.StatusUnauthorized, apperror.CodeSessionRevoked, "Session has been revoked")
+				}
+				utils.TouchSession(c.Request().Context(), claims.SessionID, c.RealIP())
+			}
+
+			setClaims(c, claims)
+
+			return next(c)
+		}
+	}
+}
+
+func OptionalJWTMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tokenParts := strings.Split(c.Request().Header.Get("Authorization"), " ")
+			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+				return next(c)
+			}
+
+			claims, err := utils.ValidateJWT(tokenParts[1])
+			if err != nil || utils.IsTokenRevoked(c.Request().Context(), claims) {
+				return next(c)
+			}
+			if claims.SessionID != "" {
+				// A session that cannot be checked is treated as anonymous.
+				if active, err := utils.IsSessionActive(c.Request().Context(), claims.SessionID); err != nil || !active {
+					return next(c)
+				}
+			}
+
+			setClaims(c, claims)
 
 			return next(c)
 		}
 	}
 }
+
+func setClaims(c echo.Context, claims *utils.JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("user_role", claims.Role)
+	c.Set("mfa", claims.MFA)
+	if claims.SessionID != "" {
+		c.Set("session_id", claims.SessionID)
+	}
+	if claims.ImpersonatorID != nil {
+		c.Set("impersonator_id", *claims.ImpersonatorID)
+	}
+	annotateUser(c)
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/metrics"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware records the count and latency of every request, labelled
+// with the route pattern rather than the raw path to keep cardinality low.
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := responseStatus(c, err)
+			route := c.Path()
+			if route == "" || status == http.StatusNotFound && route == "/*" {
+				route = "unmatched"
+			}
+			metrics.ObserveHTTPRequest(c.Request().Method, route, status, time.Since(start))
+			return err
+		}
+	}
+}
+
+// responseStatus is the status the client receives. Errors returned by a
+// handler are only rendered after the middleware chain unwinds, so their code
+// is taken from the error itself.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	if appErr, ok := apperror.As(err); ok {
+		return appErr.Status
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/metrics"
+	"PropertyListingSys/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Rate limit policies, one per route group.
+const (
+	RateLimitAuth   = "auth"
+	RateLimitPublic = "public"
+	RateLimitAPI    = "api"
+)
+
+func rateLimitRule(policy string) utils.RateLimitRule {
+	cfg := config.App.RateLimit
+	switch policy {
+	case RateLimitAuth:
+		return utils.RateLimitRule{Limit: cfg.AuthRequests, Window: time.Duration(cfg.AuthWindowSeconds) * time.Second}
+	case RateLimitPublic:
+		return utils.RateLimitRule{Limit: cfg.PublicRequests, Window: time.Duration(cfg.PublicWindowSeconds) * time.Second}
+	case RateLimitAPI:
+		return utils.RateLimitRule{Limit: cfg.APIRequests, Window: time.Duration(cfg.APIWindowSeconds) * time.Second}
+	}
+	panic("middleware: unknown rate limit policy " + policy)
+}
+
+// RateLimit enforces the policy's quota in a sliding window shared by all
+// instances through Redis. Callers are counted by API key, else by user, else
+// by client IP, so it must run after authentication to count by identity.
+// Every response carries RateLimit-* headers; over quota it fails with 429
+// and Retry-After. If Redis is unavailable requests are let through.
+func RateLimit(policy string) echo.MiddlewareFunc {
+	rule := rateLimitRule(policy)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !config.App.RateLimit.Enabled {
+			return next
+		}
+		return func(c echo.Context) error {
+			key := "ratelimit:" + policy + ":" + rateLimitSubject(c)
+			result, err := utils.AllowRequest(c.Request().Context(), key, rule)
+			if err != nil {
+				logging.For(c).Warn("Rate limit check failed; allowing request", "policy", policy, "error", err)
+				return next(c)
+			}
+
+			reset := strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", reset)
+			header.Set("RateLimit-Policy", strconv.Itoa(rule.Limit)+";w="+strconv.Itoa(int(rule.Window.Seconds())))
+
+			if !result.Allowed {
+				metrics.RecordRateLimited(policy)
+				header.Set("Retry-After", reset)
+				return apperror.New(http.StatusTooManyRequests, apperror.CodeRateLimited, "Rate limit exceeded; try again later")
+			}
+			return next(c)
+		}
+	}
+}
+
+func rateLimitSubject(c echo.Context) string {
+	if keyID, ok := c.Get("api_key_id").(primitive.ObjectID); ok {
+		return "key:" + keyID.Hex()
+	}
+	if userID, ok := c.Get("user_id").(primitive.ObjectID); ok {
+		return "user:" + userID.Hex()
+	}
+	return "ip:" + c.RealIP()
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"PropertyListingSys/apperror"
+	"PropertyListingSys/utils"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("user_role").(string)
+			if !utils.HasPermission(role, permission) {
+				return apperror.New(http.StatusForbidden, apperror.CodeForbidden, "Access denied")
+			}
+			if scopes, ok := c.Get("api_key_scopes").([]string); ok && !utils.HasScope(scopes, permission) {
+				return apperror.New(http.StatusForbidden, apperror.CodeInsufficientScope, "API key is missing the required scope")
+			}
+			if mfa, _ := c.Get("mfa").(bool); !mfa {
+				required, err := utils.TwoFactorRequired(c.Request().Context(), role)
+				if err != nil {
+					return apperror.New(http.StatusServiceUnavailable, apperror.CodeServiceUnavailable, "Security settings are unavailable; try again later").WithCause(err)
+				}
+				if required {
+					return apperror.New(http.StatusForbidden, apperror.CodeTwoFactorRequired, "Two-factor authentication is required for your role; enable it and log in again")
+				}
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"PropertyListingSys/logging"
+	"PropertyListingSys/utils"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxRequestIDLength = 128
+
+// RequestLogger assigns every request an ID, taken from a well-formed
+// incoming X-Request-ID header or generated, and echoes it in the response.
+// It stores a logger carrying the request ID, route and trace ID in the
+// request context for handlers and writes one access log line per request.
+func RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(requestID) {
+				requestID, _ = utils.GenerateToken(16)
+			}
+			c.Set("request_id", requestID)
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			attrs := []any{"request_id", requestID, "method", req.Method, "route", c.Path()}
+			if span := trace.SpanContextFromContext(req.Context()); span.HasTraceID() {
+				attrs = append(attrs, "trace_id", span.TraceID().String())
+			}
+			c.SetRequest(req.WithContext(logging.NewContext(req.Context(), slog.Default().With(attrs...))))
+
+			err := next(c)
+
+			status := responseStatus(c, err)
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			fields := []any{
+				"path", req.URL.Path,
+				"status", status,
+				"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"bytes_out", c.Response().Size,
+				"remote_ip", c.RealIP(),
+				"user_agent", req.UserAgent(),
+			}
+			if err != nil {
+				fields = append(fields, "error", err.Error())
+			}
+			logging.For(c).Log(c.Request().Context(), level, "request", fields...)
+			return err
+		}
+	}
+}
+
+// validRequestID accepts IDs from upstream proxies only if they are short and
+// limited to characters that are safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
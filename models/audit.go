@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditLog struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ActorID   primitive.ObjectID     `bson:"actorId" json:"actorId"`
+	Action    string                 `bson:"action" json:"action"`
+	TargetID  primitive.ObjectID     `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	IP        string                 `bson:"ip" json:"ip"`
+	CreatedAt time.Time              `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,42 @@
+package models
+
+const (
+	BatchStatusCreated   = "created"
+	BatchStatusUpdated   = "updated"
+	BatchStatusConflict  = "conflict"
+	BatchStatusInvalid   = "invalid"
+	BatchStatusForbidden = "forbidden"
+	BatchStatusNotFound  = "not_found"
+	BatchStatusFailed    = "failed"
+)
+
+type BatchCreateRequest struct {
+	Properties []Property `json:"properties"`
+	Upsert     bool       `json:"upsert"`
+}
+
+type BatchPatchItem struct {
+	ExternalID string                 `json:"externalId"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+type BatchPatchRequest struct {
+	Updates []BatchPatchItem `json:"updates"`
+}
+
+type BatchItemResult struct {
+	ExternalID string    `json:"externalId"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Property   *Property `json:"property,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchItemResult `json:"results"`
+	Summary map[string]int    `json:"summary"`
+}
+
+type BatchGetResponse struct {
+	Properties []Property `json:"properties"`
+	Missing    []string   `json:"missing"`
+}
@@ -0,0 +1,25 @@
+package models
+
+type PropertyComparison struct {
+	Properties []Property                   `json:"properties"`
+	Fields     []ComparedField              `json:"fields"`
+	Metrics    map[string]ComparisonMetrics `json:"metrics"`
+	Amenities  AmenityComparison            `json:"amenities"`
+}
+
+type ComparedField struct {
+	Field     string        `json:"field"`
+	Values    []interface{} `json:"values"`
+	Different bool          `json:"different"`
+}
+
+type ComparisonMetrics struct {
+	PricePerSqFt float64 `json:"pricePerSqFt"`
+	AmenityCount int     `json:"amenityCount"`
+}
+
+type AmenityComparison struct {
+	Shared  []string            `json:"shared"`
+	Unique  map[string][]string `json:"unique"`
+	Overlap float64             `json:"overlap"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "PropertyListingSys/apperror"
+
+// ErrorResponse is the body of every error response. Error holds the human
+// readable message, as in earlier versions of the API; Code is stable and
+// meant for programmatic checks.
+type ErrorResponse struct {
+	Error     string                `json:"error"`
+	Code      string                `json:"code"`
+	Details   []apperror.FieldError `json:"details,omitempty"`
+	RequestID string                `json:"requestId,omitempty"`
+}
+
+// ProblemDetails is the RFC 7807 form of ErrorResponse.
+type ProblemDetails struct {
+	Type      string                `json:"type"`
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail"`
+	Instance  string                `json:"instance,omitempty"`
+	Code      string                `json:"code"`
+	Errors    []apperror.FieldError `json:"errors,omitempty"`
+	RequestID string                `json:"requestId,omitempty"`
+}
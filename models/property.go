@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -29,3 +30,24 @@ type Property struct {
 	CreatedAt     time.Time           `bson:"createdAt" json:"createdAt"`
 	UpdatedAt     time.Time           `bson:"updatedAt" json:"updatedAt"`
 }
+
+func (p Property) AmenityList() []string {
+	return splitList(p.Amenities)
+}
+
+func (p Property) TagList() []string {
+	return splitList(p.Tags)
+}
+
+func splitList(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '|' || r == ','
+	})
+	items := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if item := strings.ToLower(strings.TrimSpace(field)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -6,10 +6,28 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RecommendationStatusDelivered = "delivered"
+	RecommendationStatusPending   = "pending"
+)
+
 type Recommendation struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	RecommenderID primitive.ObjectID `bson:"recommenderId" json:"recommenderId"`
-	RecipientID   primitive.ObjectID `bson:"recipientId" json:"recipientId"`
-	PropertyID    string             `bson:"propertyId" json:"propertyId"`
-	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RecommenderID  primitive.ObjectID `bson:"recommenderId" json:"recommenderId"`
+	RecipientID    primitive.ObjectID `bson:"recipientId,omitempty" json:"recipientId,omitempty"`
+	RecipientEmail string             `bson:"recipientEmail,omitempty" json:"recipientEmail,omitempty"`
+	PropertyID     string             `bson:"propertyId" json:"propertyId"`
+	Status         string             `bson:"status,omitempty" json:"status,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type PersonalizedRecommendation struct {
+	Property Property `json:"property"`
+	Score    float64  `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+type SimilarProperty struct {
+	Property Property `json:"property"`
+	Distance float64  `json:"distance"`
 }
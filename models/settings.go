@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+type SecuritySettings struct {
+	ID                     string    `bson:"_id" json:"-"`
+	TwoFactorRequiredRoles []string  `bson:"twoFactorRequiredRoles" json:"twoFactorRequiredRoles"`
+	UpdatedAt              time.Time `bson:"updatedAt" json:"updatedAt"`
+}
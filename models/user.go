@@ -16,6 +16,39 @@ type User struct {
 	IsActive  bool               `json:"is_active" bson:"is_active" default:"true"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
+
+	PasswordResetRequired bool `json:"password_reset_required,omitempty" bson:"password_reset_required,omitempty"`
+	// EmailVerified is set once the user has shown they control the address,
+	// by a password reset link or an identity provider that verified it.
+	EmailVerified bool `json:"email_verified" bson:"email_verified,omitempty"`
+	// TokensRevokedAt mirrors the Redis revocation marker so revocation
+	// still holds when Redis is unavailable.
+	TokensRevokedAt *time.Time `json:"-" bson:"tokens_revoked_at,omitempty"`
+
+	TOTPEnabled       bool     `json:"totp_enabled" bson:"totp_enabled,omitempty"`
+	TOTPSecret        string   `json:"-" bson:"totp_secret,omitempty"`
+	TOTPPendingSecret string   `json:"-" bson:"totp_pending_secret,omitempty"`
+	BackupCodes       []string `json:"-" bson:"backup_codes,omitempty"`
+
+	Identities []FederatedIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
+
+	DiscoverableByEmail bool   `json:"discoverable_by_email" bson:"discoverable_by_email,omitempty"`
+	Handle              string `json:"handle,omitempty" bson:"handle,omitempty"`
+	InviteCode          string `json:"-" bson:"invite_code,omitempty"`
+
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty" bson:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" bson:"deletion_scheduled_at,omitempty"`
+	// DeletionStartedAt is set when a purge claims the account; from then on
+	// the deletion can no longer be cancelled.
+	DeletionStartedAt *time.Time `json:"-" bson:"deletion_started_at,omitempty"`
+}
+
+// FederatedIdentity links a user to an account at an external OIDC provider.
+type FederatedIdentity struct {
+	Issuer   string    `json:"issuer" bson:"issuer"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
 }
 
 type LoginRequest struct {
@@ -39,3 +72,82 @@ type UpdateUserRequest struct {
 	Name  string `json:"name"`
 	Phone string `json:"phone"`
 }
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type UpdateUserStatusRequest struct {
+	Active *bool `json:"active" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+type UserListResponse struct {
+	Users []User `json:"users"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Total int64  `json:"total"`
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code"`
+	BackupCode     string `json:"backup_code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+type TwoFactorPolicyRequest struct {
+	RequiredRoles []string `json:"required_roles"`
+}
+
+type AccountDeletionResponse struct {
+	Message             string    `json:"message"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+type PrivacySettings struct {
+	DiscoverableByEmail bool   `json:"discoverable_by_email"`
+	Handle              string `json:"handle"`
+	InviteCode          string `json:"invite_code,omitempty"`
+}
+
+type UpdatePrivacyRequest struct {
+	DiscoverableByEmail *bool   `json:"discoverable_by_email"`
+	Handle              *string `json:"handle"`
+}
+
+// UserLookup identifies another user by exactly one of email, handle or
+// invite code. Email only matches users who opted in to being discoverable.
+type UserLookup struct {
+	Email      string `json:"recipientEmail" query:"email"`
+	Handle     string `json:"recipientHandle" query:"handle"`
+	InviteCode string `json:"recipientInviteCode" query:"invite_code"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PropertyView struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	PropertyID string             `bson:"propertyId" json:"propertyId"`
+	ViewedAt   time.Time          `bson:"viewedAt" json:"viewedAt"`
+}
@@ -0,0 +1,125 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// The spec is maintained as YAML for readability and served as JSON.
+//
+//go:embed openapi.yaml
+var specYAML []byte
+
+var specJSON = mustJSON(specYAML)
+
+var pathParam = regexp.MustCompile(`:([^/]+)`)
+
+var methods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+func mustJSON(source []byte) []byte {
+	var doc any
+	if err := yaml.Unmarshal(source, &doc); err != nil {
+		panic("openapi: invalid openapi.yaml: " + err.Error())
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: openapi.yaml cannot be converted to JSON: " + err.Error())
+	}
+	return data
+}
+
+// SpecHandler serves the OpenAPI document at /openapi.json.
+func SpecHandler(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSONBlob(http.StatusOK, specJSON)
+}
+
+// DocsHandler serves a Swagger UI page for the OpenAPI document.
+func DocsHandler(c echo.Context) error {
+	return c.HTML(http.StatusOK, docsPage)
+}
+
+// CheckRoutes reports routes registered on the server that the spec does not
+// document, and documented operations with no matching route, so the two
+// cannot drift apart unnoticed.
+func CheckRoutes(routes []*echo.Route) error {
+	documented, err := operations()
+	if err != nil {
+		return err
+	}
+
+	registered := map[string]bool{}
+	for _, route := range routes {
+		if !slices.Contains(methods, route.Method) {
+			// Echo registers internal not-found routes for groups.
+			continue
+		}
+		registered[route.Method+" "+pathParam.ReplaceAllString(route.Path, "{$1}")] = true
+	}
+
+	var problems []string
+	for op := range registered {
+		if !documented[op] {
+			problems = append(problems, "undocumented route: "+op)
+		}
+	}
+	for op := range documented {
+		if !registered[op] {
+			problems = append(problems, "documented operation has no route: "+op)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return errors.New("openapi spec does not match routes:\n  " + strings.Join(problems, "\n  "))
+}
+
+func operations() (map[string]bool, error) {
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(specJSON, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: decode spec: %w", err)
+	}
+
+	ops := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			method = strings.ToUpper(method)
+			if slices.Contains(methods, method) {
+				ops[method+" "+path] = true
+			}
+		}
+	}
+	return ops, nil
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>PropertyListingSys API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,40 @@
+package openapi_test
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/openapi"
+	"PropertyListingSys/routes"
+	"context"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	t.Setenv("MONGODB_URI", "mongodb://localhost:27017")
+	t.Setenv("MONGODB_DATABASE", "openapi-check")
+	t.Setenv("JWT_SECRET", "openapi-check")
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	config.App = cfg
+
+	// Controllers only take collection handles when routes are registered;
+	// Connect does not contact the server.
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(cfg.Mongo.URI))
+	if err != nil {
+		t.Fatalf("set up MongoDB client: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	config.Client = client
+	config.DB = client.Database(cfg.Mongo.Database)
+
+	e := echo.New()
+	routes.RegisterRoutes(e)
+	if err := openapi.CheckRoutes(e.Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
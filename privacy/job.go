@@ -0,0 +1,60 @@
+package privacy
+
+import (
+	"PropertyListingSys/models"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Run purges accounts whose deletion grace period has ended once per
+// interval until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) purgeDue(ctx context.Context) {
+	cursor, err := s.users.Find(ctx, bson.M{"deletion_scheduled_at": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		slog.Error("Privacy: failed to load accounts due for deletion", "error", err)
+		return
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		slog.Error("Privacy: failed to load accounts due for deletion", "error", err)
+		return
+	}
+
+	purged := 0
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := s.Purge(ctx, user); err != nil {
+			if errors.Is(err, ErrDeletionCancelled) {
+				slog.Info("Privacy: skipped purge; deletion was cancelled", "user_id", user.ID.Hex())
+				continue
+			}
+			slog.Error("Privacy: failed to purge user", "user_id", user.ID.Hex(), "error", err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		slog.Info("Privacy: purged accounts", "accounts", purged)
+	}
+}
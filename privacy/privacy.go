@@ -0,0 +1,242 @@
+package privacy
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/models"
+	"PropertyListingSys/recommender"
+	"PropertyListingSys/utils"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const AuditActionUserDeleted = "user.deleted"
+
+// ErrDeletionCancelled is returned by Purge when the account's deletion was
+// cancelled, or is not yet due, so nothing was erased.
+var ErrDeletionCancelled = errors.New("account deletion is no longer scheduled")
+
+// Service exports a user's personal data and erases it once the deletion
+// grace period has passed.
+type Service struct {
+	users           *mongo.Collection
+	properties      *mongo.Collection
+	favorites       *mongo.Collection
+	recommendations *mongo.Collection
+	views           *mongo.Collection
+	sessions        *mongo.Collection
+	apiKeys         *mongo.Collection
+	audit           *mongo.Collection
+}
+
+func NewService() *Service {
+	collections := config.App.Mongo.Collections
+	return &Service{
+		users:           config.GetCollection(collections.User),
+		properties:      config.GetCollection(collections.Properties),
+		favorites:       config.GetCollection(collections.Favorites),
+		recommendations: config.GetCollection(collections.Recommendations),
+		views:           config.GetCollection(collections.Views),
+		sessions:        utils.SessionCollection(),
+		apiKeys:         utils.APIKeyCollection(),
+		audit:           config.GetCollection(collections.Audit),
+	}
+}
+
+// GracePeriod is how long a requested deletion can still be cancelled.
+func GracePeriod() time.Duration {
+	return time.Duration(config.App.Privacy.DeletionGraceDays) * 24 * time.Hour
+}
+
+// Interval is how often the purge job looks for accounts whose grace period
+// has ended.
+func Interval() time.Duration {
+	return time.Duration(config.App.Privacy.PurgeIntervalMinutes) * time.Minute
+}
+
+// Export builds a zip archive with one JSON file per kind of personal data
+// held about the user.
+func (s *Service) Export(ctx context.Context, user models.User) ([]byte, error) {
+	user.Password = ""
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	if err := writeJSON(zw, "profile.json", user); err != nil {
+		return nil, err
+	}
+
+	exports := []struct {
+		file       string
+		collection *mongo.Collection
+		filter     bson.M
+		results    interface{}
+	}{
+		{"properties.json", s.properties, bson.M{"createdBy": user.ID}, &[]models.Property{}},
+		{"favorites.json", s.favorites, bson.M{"userId": user.ID}, &[]models.Favorite{}},
+		{"recommendations_sent.json", s.recommendations, bson.M{"recommenderId": user.ID}, &[]models.Recommendation{}},
+		{"recommendations_received.json", s.recommendations, bson.M{"recipientId": user.ID}, &[]models.Recommendation{}},
+		{"property_views.json", s.views, bson.M{"userId": user.ID}, &[]models.PropertyView{}},
+		{"sessions.json", s.sessions, bson.M{"userId": user.ID}, &[]models.Session{}},
+		{"api_keys.json", s.apiKeys, bson.M{"userId": user.ID}, &[]models.APIKey{}},
+	}
+	for _, export := range exports {
+		cursor, err := export.collection.Find(ctx, export.filter)
+		if err != nil {
+			return nil, err
+		}
+		if err := cursor.All(ctx, export.results); err != nil {
+			return nil, err
+		}
+		if err := writeJSON(zw, export.file, export.results); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Purge erases the user: personal records are deleted, listings they created
+// are kept but detached from them, and the user document is removed last so
+// a failed run is retried on the next pass. Audit logs are retained and a
+// final entry records the deletion.
+//
+// The account must be scheduled for deletion by now. Purge first claims it,
+// which stops the deletion from being cancelled while records are removed.
+func (s *Service) Purge(ctx context.Context, user models.User) (map[string]int64, error) {
+	counts := map[string]int64{}
+	now := time.Now()
+	due := bson.M{"_id": user.ID, "deletion_scheduled_at": bson.M{"$lte": now}}
+
+	claimed, err := s.users.UpdateOne(ctx, due, bson.M{"$set": bson.M{"deletion_started_at": now}})
+	if err != nil {
+		return nil, err
+	}
+	if claimed.MatchedCount == 0 {
+		return nil, ErrDeletionCancelled
+	}
+
+	if err := utils.RevokeUserTokens(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	var apiKeys []models.APIKey
+	cursor, err := s.apiKeys.Find(ctx, bson.M{"userId": user.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &apiKeys); err != nil {
+		return nil, err
+	}
+
+	recipients, err := s.recommendations.Distinct(ctx, "recipientId", bson.M{"recommenderId": user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	listingIDs, err := s.properties.Distinct(ctx, "_id", bson.M{"createdBy": user.ID})
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.properties.UpdateMany(ctx, bson.M{"createdBy": user.ID}, bson.M{"$set": bson.M{"createdBy": nil}})
+	if err != nil {
+		return nil, err
+	}
+	counts["properties_anonymised"] = updated.ModifiedCount
+
+	deletes := []struct {
+		name       string
+		collection *mongo.Collection
+		filter     bson.M
+	}{
+		{"favorites", s.favorites, bson.M{"userId": user.ID}},
+		{"recommendations", s.recommendations, bson.M{"$or": []bson.M{
+			{"recommenderId": user.ID},
+			{"recipientId": user.ID},
+			{"recipientEmail": user.Email},
+		}}},
+		{"views", s.views, bson.M{"userId": user.ID}},
+		{"sessions", s.sessions, bson.M{"userId": user.ID}},
+		{"api_keys", s.apiKeys, bson.M{"userId": user.ID}},
+	}
+	for _, d := range deletes {
+		result, err := d.collection.DeleteMany(ctx, d.filter)
+		if err != nil {
+			return nil, err
+		}
+		counts[d.name] = result.DeletedCount
+	}
+
+	deleted, err := s.users.DeleteOne(ctx, due)
+	if err != nil {
+		return nil, err
+	}
+	if deleted.DeletedCount == 0 {
+		// Cancellation is refused once the claim is set, so this only
+		// happens if the schedule was changed behind the API's back. Release
+		// the claim and leave what remains of the account.
+		s.users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"deletion_started_at": ""}})
+		return nil, ErrDeletionCancelled
+	}
+
+	keys := []string{
+		"user:profile:" + user.ID.Hex(),
+		"user:email:" + user.Email,
+		"users:all",
+		"favorites:" + user.ID.Hex(),
+		"recommendations:" + user.ID.Hex(),
+		recommender.CacheKey(user.ID),
+	}
+	for _, apiKey := range apiKeys {
+		keys = append(keys, utils.APIKeyCacheKey(apiKey.Hash))
+	}
+	for _, id := range recipients {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			keys = append(keys, "recommendations:"+oid.Hex())
+		}
+	}
+	for _, id := range listingIDs {
+		if propertyID, ok := id.(string); ok {
+			keys = append(keys, "property:"+propertyID)
+		}
+	}
+	utils.RedisClient.Del(ctx, keys...)
+	if len(listingIDs) > 0 {
+		utils.InvalidatePropertyLists(ctx)
+		recommender.InvalidateSimilar(ctx)
+	}
+
+	details := map[string]interface{}{"counts": counts}
+	if user.DeletionRequestedAt != nil {
+		details["requestedAt"] = *user.DeletionRequestedAt
+	}
+	_, err = s.audit.InsertOne(ctx, models.AuditLog{
+		ID:        primitive.NewObjectID(),
+		ActorID:   user.ID,
+		Action:    AuditActionUserDeleted,
+		TargetID:  user.ID,
+		Details:   details,
+		CreatedAt: time.Now(),
+	})
+	return counts, err
+}
@@ -0,0 +1,370 @@
+package recommender
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	favoriteWeight       = 3.0
+	recommendationWeight = 2.0
+	viewWeight           = 1.0
+
+	typeWeight     = 0.25
+	cityWeight     = 0.25
+	priceWeight    = 0.2
+	bedroomsWeight = 0.15
+	amenityWeight  = 0.15
+	coFavWeight    = 0.5
+
+	viewHistoryWindow = 30 * 24 * time.Hour
+	maxCandidates     = 500
+	maxCoFavUsers     = 500
+	maxCoFavItems     = 100
+)
+
+type Engine struct {
+	properties      *mongo.Collection
+	favorites       *mongo.Collection
+	recommendations *mongo.Collection
+	views           *mongo.Collection
+	limit           int
+	ttl             time.Duration
+	weights         SimilarityWeights
+}
+
+func NewEngine() *Engine {
+	collections := config.App.Mongo.Collections
+	return &Engine{
+		properties:      config.GetCollection(collections.Properties),
+		favorites:       config.GetCollection(collections.Favorites),
+		recommendations: config.GetCollection(collections.Recommendations),
+		views:           config.GetCollection(collections.Views),
+		limit:           config.App.Recommender.Limit,
+		ttl:             2 * Interval(),
+		weights:         LoadSimilarityWeights(),
+	}
+}
+
+func Interval() time.Duration {
+	return time.Duration(config.App.Recommender.IntervalMinutes) * time.Minute
+}
+
+func CacheKey(userID primitive.ObjectID) string {
+	return "recommended:" + userID.Hex()
+}
+
+// Get returns the cached recommendations for a user, computing and caching
+// them on a miss so a user is never blocked on the next job run.
+func (e *Engine) Get(ctx context.Context, userID primitive.ObjectID) ([]models.PersonalizedRecommendation, error) {
+	var recs []models.PersonalizedRecommendation
+	if hit, err := utils.GetCached(ctx, CacheKey(userID), &recs); hit && err == nil {
+		return recs, nil
+	}
+	return e.Refresh(ctx, userID)
+}
+
+func (e *Engine) Refresh(ctx context.Context, userID primitive.ObjectID) ([]models.PersonalizedRecommendation, error) {
+	recs, err := e.Compute(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.SetCached(ctx, CacheKey(userID), recs, e.ttl); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", CacheKey(userID), "error", err)
+	}
+	return recs, nil
+}
+
+type profile struct {
+	total     float64
+	types     map[string]float64
+	cities    map[string]float64
+	bands     map[int]float64
+	bedrooms  map[int]float64
+	amenities map[string]float64
+}
+
+func newProfile() *profile {
+	return &profile{
+		types:     map[string]float64{},
+		cities:    map[string]float64{},
+		bands:     map[int]float64{},
+		bedrooms:  map[int]float64{},
+		amenities: map[string]float64{},
+	}
+}
+
+func (p *profile) add(property models.Property, weight float64) {
+	p.total += weight
+	p.types[property.Type] += weight
+	p.cities[property.City] += weight
+	p.bands[priceBand(property.Price)] += weight
+	p.bedrooms[property.Bedrooms] += weight
+	for _, amenity := range property.AmenityList() {
+		p.amenities[amenity] += weight
+	}
+}
+
+func (p *profile) score(property models.Property) (float64, []string) {
+	if p.total == 0 {
+		return 0, nil
+	}
+
+	var score float64
+	var reasons []string
+	if w := p.types[property.Type] / p.total; w > 0 {
+		score += typeWeight * w
+		reasons = append(reasons, "type:"+property.Type)
+	}
+	if w := p.cities[property.City] / p.total; w > 0 {
+		score += cityWeight * w
+		reasons = append(reasons, "city:"+property.City)
+	}
+	if w := p.bands[priceBand(property.Price)] / p.total; w > 0 {
+		score += priceWeight * w
+		reasons = append(reasons, "price_band")
+	}
+	if w := p.bedrooms[property.Bedrooms] / p.total; w > 0 {
+		score += bedroomsWeight * w
+		reasons = append(reasons, "bedrooms:"+strconv.Itoa(property.Bedrooms))
+	}
+	if amenities := property.AmenityList(); len(amenities) > 0 {
+		var sum float64
+		for _, amenity := range amenities {
+			sum += p.amenities[amenity]
+		}
+		if sum > 0 {
+			score += amenityWeight * sum / (p.total * float64(len(amenities)))
+			reasons = append(reasons, "amenities")
+		}
+	}
+	return score, reasons
+}
+
+// priceBand buckets prices on a log2 scale so each band spans a doubling.
+func priceBand(price float64) int {
+	if price <= 0 {
+		return 0
+	}
+	return int(math.Log2(price))
+}
+
+func (e *Engine) Compute(ctx context.Context, userID primitive.ObjectID) ([]models.PersonalizedRecommendation, error) {
+	favoriteIDs, err := e.favorites.Distinct(ctx, "propertyId", bson.M{"userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	seeds, err := e.seedWeights(ctx, userID, favoriteIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	favorited := make(map[string]bool, len(favoriteIDs))
+	favoriteList := make([]string, 0, len(favoriteIDs))
+	for _, id := range favoriteIDs {
+		if s, ok := id.(string); ok {
+			favorited[s] = true
+			favoriteList = append(favoriteList, s)
+		}
+	}
+
+	userProfile := newProfile()
+	if len(seeds) > 0 {
+		seedIDs := make([]string, 0, len(seeds))
+		for id := range seeds {
+			seedIDs = append(seedIDs, id)
+		}
+		seedProperties, err := e.findProperties(ctx, bson.M{"_id": bson.M{"$in": seedIDs}}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, property := range seedProperties {
+			userProfile.add(property, seeds[property.ExternalID])
+		}
+	}
+
+	coFav, err := e.coFavorites(ctx, userID, favoriteList)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxCoFav float64
+	coFavIDs := make([]string, 0, len(coFav))
+	for id, count := range coFav {
+		coFavIDs = append(coFavIDs, id)
+		maxCoFav = math.Max(maxCoFav, count)
+	}
+
+	candidateFilters := bson.A{}
+	if len(userProfile.types) > 0 {
+		candidateFilters = append(candidateFilters, bson.M{"type": bson.M{"$in": stringKeys(userProfile.types)}})
+	}
+	if len(userProfile.cities) > 0 {
+		candidateFilters = append(candidateFilters, bson.M{"city": bson.M{"$in": stringKeys(userProfile.cities)}})
+	}
+
+	var candidates []models.Property
+	if len(candidateFilters) > 0 {
+		candidates, err = e.findProperties(ctx, bson.M{
+			"$or": candidateFilters,
+			"_id": bson.M{"$nin": favoriteList},
+		}, maxCandidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(coFavIDs) > 0 {
+		coFavProperties, err := e.findProperties(ctx, bson.M{"_id": bson.M{"$in": coFavIDs}}, 0)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, coFavProperties...)
+	}
+
+	recs := make([]models.PersonalizedRecommendation, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for _, property := range candidates {
+		if favorited[property.ExternalID] || seen[property.ExternalID] {
+			continue
+		}
+		seen[property.ExternalID] = true
+		score, reasons := userProfile.score(property)
+		if count := coFav[property.ExternalID]; count > 0 {
+			score += coFavWeight * count / maxCoFav
+			reasons = append(reasons, "co_favorited")
+		}
+		if score <= 0 {
+			continue
+		}
+		recs = append(recs, models.PersonalizedRecommendation{
+			Property: property,
+			Score:    math.Round(score*1000) / 1000,
+			Reasons:  reasons,
+		})
+	}
+
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].Score > recs[j].Score
+	})
+	if len(recs) > e.limit {
+		recs = recs[:e.limit]
+	}
+	return recs, nil
+}
+
+func (e *Engine) seedWeights(ctx context.Context, userID primitive.ObjectID, favoriteIDs []interface{}) (map[string]float64, error) {
+	seeds := map[string]float64{}
+	addSeeds(seeds, favoriteIDs, favoriteWeight)
+
+	recommendedIDs, err := e.recommendations.Distinct(ctx, "propertyId", bson.M{"recipientId": userID})
+	if err != nil {
+		return nil, err
+	}
+	addSeeds(seeds, recommendedIDs, recommendationWeight)
+
+	viewedIDs, err := e.views.Distinct(ctx, "propertyId", bson.M{
+		"userId":   userID,
+		"viewedAt": bson.M{"$gte": time.Now().Add(-viewHistoryWindow)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	addSeeds(seeds, viewedIDs, viewWeight)
+
+	return seeds, nil
+}
+
+func addSeeds(seeds map[string]float64, ids []interface{}, weight float64) {
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			seeds[s] += weight
+		}
+	}
+}
+
+// coFavorites counts, for every property not yet favorited by the user, how
+// many users who share at least one favorite with them also favorited it.
+func (e *Engine) coFavorites(ctx context.Context, userID primitive.ObjectID, favoriteIDs []string) (map[string]float64, error) {
+	counts := map[string]float64{}
+	if len(favoriteIDs) == 0 {
+		return counts, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"propertyId": bson.M{"$in": favoriteIDs}, "userId": bson.M{"$ne": userID}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$userId"}}},
+		{{Key: "$limit", Value: maxCoFavUsers}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         e.favorites.Name(),
+			"localField":   "_id",
+			"foreignField": "userId",
+			"as":           "favs",
+		}}},
+		{{Key: "$unwind", Value: "$favs"}},
+		{{Key: "$match", Value: bson.M{"favs.propertyId": bson.M{"$nin": favoriteIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$favs.propertyId", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.M{"count": -1}}},
+		{{Key: "$limit", Value: maxCoFavItems}},
+	}
+
+	cursor, err := e.favorites.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var row struct {
+			PropertyID string `bson:"_id"`
+			Count      int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable co-favorite row", "error", err)
+			continue
+		}
+		counts[row.PropertyID] = float64(row.Count)
+	}
+	return counts, cursor.Err()
+}
+
+func (e *Engine) findProperties(ctx context.Context, filter bson.M, limit int64) ([]models.Property, error) {
+	opts := options.Find()
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cursor, err := e.properties.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var properties []models.Property
+	for cursor.Next(ctx) {
+		var property models.Property
+		if err := cursor.Decode(&property); err != nil {
+			logging.FromContext(ctx).Warn("Skipping undecodable property document", "error", err)
+			continue
+		}
+		properties = append(properties, property)
+	}
+	return properties, cursor.Err()
+}
+
+func stringKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,81 @@
+package recommender
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run precomputes recommendations for every user with recent activity once
+// per interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.refreshActiveUsers(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Engine) refreshActiveUsers(ctx context.Context) {
+	userIDs, err := e.activeUsers(ctx)
+	if err != nil {
+		slog.Error("Recommender: failed to load active users", "error", err)
+		return
+	}
+
+	refreshed := 0
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := e.Refresh(ctx, userID); err != nil {
+			slog.Error("Recommender: failed to refresh user", "user_id", userID.Hex(), "error", err)
+			continue
+		}
+		refreshed++
+	}
+	slog.Info("Recommender: refreshed recommendations", "users", refreshed)
+}
+
+func (e *Engine) activeUsers(ctx context.Context) ([]primitive.ObjectID, error) {
+	seen := map[primitive.ObjectID]bool{}
+	var userIDs []primitive.ObjectID
+	collect := func(ids []interface{}) {
+		for _, id := range ids {
+			if oid, ok := id.(primitive.ObjectID); ok && !seen[oid] {
+				seen[oid] = true
+				userIDs = append(userIDs, oid)
+			}
+		}
+	}
+
+	favoriteUsers, err := e.favorites.Distinct(ctx, "userId", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	collect(favoriteUsers)
+
+	recipients, err := e.recommendations.Distinct(ctx, "recipientId", bson.M{"recipientId": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	collect(recipients)
+
+	viewers, err := e.views.Distinct(ctx, "userId", bson.M{"viewedAt": bson.M{"$gte": time.Now().Add(-viewHistoryWindow)}})
+	if err != nil {
+		return nil, err
+	}
+	collect(viewers)
+
+	return userIDs, nil
+}
@@ -0,0 +1,188 @@
+package recommender
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"PropertyListingSys/utils"
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	MaxSimilar           = 50
+	maxSimilarCandidates = 1000
+	similarCacheTTL      = 5 * time.Minute
+)
+
+type SimilarityWeights struct {
+	Price     float64 `json:"price"`
+	AreaSqFt  float64 `json:"areaSqFt"`
+	Bedrooms  float64 `json:"bedrooms"`
+	Bathrooms float64 `json:"bathrooms"`
+	Type      float64 `json:"type"`
+	City      float64 `json:"city"`
+	Amenities float64 `json:"amenities"`
+}
+
+func LoadSimilarityWeights() SimilarityWeights {
+	w := config.App.Recommender.Weights
+	return SimilarityWeights{
+		Price:     w.Price,
+		AreaSqFt:  w.Area,
+		Bedrooms:  w.Bedrooms,
+		Bathrooms: w.Bathrooms,
+		Type:      w.Type,
+		City:      w.City,
+		Amenities: w.Amenities,
+	}
+}
+
+func (w SimilarityWeights) total() float64 {
+	return w.Price + w.AreaSqFt + w.Bedrooms + w.Bathrooms + w.Type + w.City + w.Amenities
+}
+
+// Distance returns a weighted distance in [0, 1] between two properties,
+// where 0 means identical on every compared attribute.
+func Distance(a, b models.Property, w SimilarityWeights) float64 {
+	total := w.total()
+	if total == 0 {
+		return 0
+	}
+
+	d := w.Price*relativeDiff(a.Price, b.Price) +
+		w.AreaSqFt*relativeDiff(a.AreaSqFt, b.AreaSqFt) +
+		w.Bedrooms*relativeDiff(float64(a.Bedrooms), float64(b.Bedrooms)) +
+		w.Bathrooms*relativeDiff(float64(a.Bathrooms), float64(b.Bathrooms)) +
+		w.Type*mismatch(a.Type, b.Type) +
+		w.City*mismatch(a.City, b.City) +
+		w.Amenities*(1-Jaccard(a.AmenityList(), b.AmenityList()))
+
+	return d / total
+}
+
+func relativeDiff(a, b float64) float64 {
+	largest := math.Max(math.Abs(a), math.Abs(b))
+	if largest == 0 {
+		return 0
+	}
+	return math.Min(math.Abs(a-b)/largest, 1)
+}
+
+func mismatch(a, b string) float64 {
+	if a == b {
+		return 0
+	}
+	return 1
+}
+
+func Jaccard(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	return float64(len(Intersect(a, b))) / float64(len(union(a, b)))
+}
+
+func Intersect(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, item := range b {
+		inB[item] = true
+	}
+	seen := map[string]bool{}
+	shared := []string{}
+	for _, item := range a {
+		if inB[item] && !seen[item] {
+			seen[item] = true
+			shared = append(shared, item)
+		}
+	}
+	return shared
+}
+
+func union(a, b []string) map[string]bool {
+	all := make(map[string]bool, len(a)+len(b))
+	for _, item := range a {
+		all[item] = true
+	}
+	for _, item := range b {
+		all[item] = true
+	}
+	return all
+}
+
+const similarGenerationKey = "similar:generation"
+
+// similarCacheKey includes the current similar-cache generation, so bumping
+// it with InvalidateSimilar retires every cached list at once. A changed or
+// deleted property can appear in any other property's list, not just its own.
+func similarCacheKey(ctx context.Context, propertyID string) string {
+	generation, err := utils.RedisClient.Get(ctx, similarGenerationKey).Result()
+	if err != nil {
+		generation = "0"
+	}
+	return "similar:" + generation + ":" + propertyID
+}
+
+// InvalidateSimilar discards all cached similar-property lists. Call it after
+// any property is created, changed or deleted.
+func InvalidateSimilar(ctx context.Context) {
+	if err := utils.RedisClient.Incr(ctx, similarGenerationKey).Err(); err != nil {
+		logging.FromContext(ctx).Warn("Failed to invalidate similar-property caches", "error", err)
+	}
+}
+
+// Similar returns up to limit properties closest to target. The full top
+// list is cached per property and sliced per request so different limits
+// share a single cache entry.
+func (e *Engine) Similar(ctx context.Context, target models.Property, limit int) ([]models.SimilarProperty, error) {
+	var similar []models.SimilarProperty
+	cacheKey := similarCacheKey(ctx, target.ExternalID)
+	if hit, err := utils.GetCached(ctx, cacheKey, &similar); !hit || err != nil {
+		similar, err = e.computeSimilar(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.SetCached(ctx, cacheKey, similar, similarCacheTTL); err != nil {
+			logging.FromContext(ctx).Warn("Failed to cache result", "key", cacheKey, "error", err)
+		}
+	}
+
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar, nil
+}
+
+func (e *Engine) computeSimilar(ctx context.Context, target models.Property) ([]models.SimilarProperty, error) {
+	candidates, err := e.findProperties(ctx, bson.M{
+		"_id": bson.M{"$ne": target.ExternalID},
+		"$or": bson.A{
+			bson.M{"city": target.City},
+			bson.M{"type": target.Type},
+		},
+	}, maxSimilarCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	similar := make([]models.SimilarProperty, 0, len(candidates))
+	for _, candidate := range candidates {
+		distance := Distance(target, candidate, e.weights)
+		similar = append(similar, models.SimilarProperty{
+			Property: candidate,
+			Distance: math.Round(distance*1000) / 1000,
+		})
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Distance < similar[j].Distance
+	})
+	if len(similar) > MaxSimilar {
+		similar = similar[:MaxSimilar]
+	}
+	return similar, nil
+}
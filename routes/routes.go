@@ -2,46 +2,104 @@ package routes
 
 import (
 	"PropertyListingSys/handlers"
+	"PropertyListingSys/metrics"
 	"PropertyListingSys/middleware"
+	"PropertyListingSys/openapi"
+	"PropertyListingSys/utils"
 
 	"github.com/labstack/echo/v4"
 )
 
 func RegisterRoutes(e *echo.Echo) {
 	e.GET("/health", handlers.HealthCheck)
+	e.GET("/livez", handlers.Livez)
+	e.GET("/readyz", handlers.Readyz)
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	e.GET("/.well-known/jwks.json", handlers.JWKS)
+	e.GET("/openapi.json", openapi.SpecHandler)
+	e.GET("/docs", openapi.DocsHandler)
 
 	userController := handlers.NewUserController()
 	propertyController := handlers.NewPropertyController()
 	favoriteController := handlers.NewFavoriteController()
 	recommendationController := handlers.NewRecommendationController()
+	adminController := handlers.NewAdminController()
+	apiKeyController := handlers.NewAPIKeyController()
+	sessionController := handlers.NewSessionController()
 
-	auth := e.Group("/api/auth")
+	auth := e.Group("/api/auth", middleware.RateLimit(middleware.RateLimitAuth))
 	auth.POST("/register", userController.Register)
 	auth.POST("/login", userController.Login)
+	auth.POST("/password/reset", userController.ResetPassword)
+	auth.POST("/unlock", userController.UnlockAccount)
+	auth.POST("/2fa", userController.VerifyTwoFactorLogin)
+	auth.GET("/oidc/login", userController.OIDCLogin)
+	auth.GET("/oidc/callback", userController.OIDCCallback)
 
+	// API keys are refused on /api routes unless the route is declared with
+	// middleware.AllowAPIKey. Account, privacy, session and 2FA management and
+	// impersonation need an interactive login.
 	api := e.Group("/api")
-	api.Use(middleware.JWTMiddleware())
+	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.RateLimit(middleware.RateLimitAPI))
 
 	users := api.Group("/users")
-	users.GET("/profile", userController.GetProfile)
+	middleware.AllowAPIKey(users.GET("/profile", userController.GetProfile), utils.ScopeProfileRead)
 	users.PUT("/profile", userController.UpdateProfile)
 	users.DELETE("/profile", userController.DeleteAccount)
-	users.GET("", userController.GetAllUsers)
-	users.GET("/search", userController.SearchUserByEmail)
+	users.POST("/profile/cancel-deletion", userController.CancelAccountDeletion)
+	users.GET("/profile/export", userController.ExportData)
+	middleware.AllowAPIKey(users.GET("", userController.GetAllUsers, middleware.RequirePermission(utils.PermUserReadAll)), utils.PermUserReadAll)
+	users.GET("/search", userController.SearchUser)
+	users.GET("/profile/privacy", userController.GetPrivacySettings)
+	users.PUT("/profile/privacy", userController.UpdatePrivacySettings)
+	users.POST("/profile/invite-code", userController.RegenerateInviteCode)
+	users.POST("/api-keys", apiKeyController.CreateAPIKey)
+	users.GET("/api-keys", apiKeyController.ListAPIKeys)
+	users.DELETE("/api-keys/:id", apiKeyController.RevokeAPIKey)
+	users.GET("/sessions", sessionController.ListSessions)
+	users.DELETE("/sessions", sessionController.RevokeOtherSessions)
+	users.DELETE("/sessions/:id", sessionController.RevokeSession)
+	users.POST("/2fa/enroll", userController.EnrollTwoFactor)
+	users.POST("/2fa/verify", userController.ConfirmTwoFactor)
+	users.POST("/2fa/disable", userController.DisableTwoFactor)
+	users.POST("/2fa/backup-codes", userController.RegenerateBackupCodes)
+	users.POST("/oidc/link", userController.LinkOIDCIdentity)
 
 	properties := api.Group("/properties")
-	properties.POST("", propertyController.CreateProperty)
-	properties.PATCH("/:id", propertyController.PatchProperty)
-	properties.DELETE("/:id", propertyController.DeleteProperty)
-	e.GET("/properties", propertyController.ListProperties)
-	e.GET("/properties/:id", propertyController.GetProperty)
+	middleware.AllowAPIKey(properties.POST("", propertyController.CreateProperty, middleware.RequirePermission(utils.PermPropertyCreate)), utils.PermPropertyCreate)
+	middleware.AllowAPIKey(properties.POST("/batch", propertyController.BatchCreateProperties, middleware.RequirePermission(utils.PermPropertyBatch), middleware.RequirePermission(utils.PermPropertyCreate)), utils.PermPropertyBatch)
+	middleware.AllowAPIKey(properties.PATCH("/batch", propertyController.BatchPatchProperties, middleware.RequirePermission(utils.PermPropertyBatch)), utils.PermPropertyBatch)
+	middleware.AllowAPIKey(properties.PATCH("/:id", propertyController.PatchProperty), utils.PermPropertyUpdateOwn, utils.PermPropertyUpdateAny)
+	middleware.AllowAPIKey(properties.DELETE("/:id", propertyController.DeleteProperty), utils.PermPropertyDeleteOwn, utils.PermPropertyDeleteAny)
+
+	publicProperties := e.Group("/properties", middleware.RateLimit(middleware.RateLimitPublic))
+	publicProperties.GET("", propertyController.ListProperties)
+	publicProperties.GET("/compare", propertyController.CompareProperties)
+	publicProperties.GET("/batch", propertyController.BatchGetProperties)
+	publicProperties.GET("/:id", propertyController.GetProperty, middleware.OptionalJWTMiddleware())
+	publicProperties.GET("/:id/similar", propertyController.GetSimilarProperties)
 
 	favorites := api.Group("/favorites")
-	favorites.POST("", favoriteController.CreateFavorite)
-	favorites.GET("", favoriteController.GetFavorites)
-	favorites.DELETE("/:propertyId", favoriteController.DeleteFavorite)
+	middleware.AllowAPIKey(favorites.POST("", favoriteController.CreateFavorite), utils.ScopeFavorites)
+	middleware.AllowAPIKey(favorites.GET("", favoriteController.GetFavorites), utils.ScopeFavorites)
+	middleware.AllowAPIKey(favorites.DELETE("/:propertyId", favoriteController.DeleteFavorite), utils.ScopeFavorites)
+
+	admin := api.Group("/admin")
+	middleware.AllowAPIKey(admin.GET("/roles", adminController.ListRoles, middleware.RequirePermission(utils.PermUserRoleManage)), utils.PermUserRoleManage)
+	middleware.AllowAPIKey(admin.GET("/users", adminController.ListUsers, middleware.RequirePermission(utils.PermUserReadAll)), utils.PermUserReadAll)
+	middleware.AllowAPIKey(admin.GET("/users/:id", adminController.GetUser, middleware.RequirePermission(utils.PermUserReadAll)), utils.PermUserReadAll)
+	middleware.AllowAPIKey(admin.PATCH("/users/:id/status", adminController.UpdateUserStatus, middleware.RequirePermission(utils.PermUserManage)), utils.PermUserManage)
+	middleware.AllowAPIKey(admin.POST("/users/:id/password-reset", adminController.ForcePasswordReset, middleware.RequirePermission(utils.PermUserManage)), utils.PermUserManage)
+	middleware.AllowAPIKey(admin.POST("/users/:id/unlock", adminController.UnlockUser, middleware.RequirePermission(utils.PermUserManage)), utils.PermUserManage)
+	middleware.AllowAPIKey(admin.PUT("/users/:id/role", adminController.AssignRole, middleware.RequirePermission(utils.PermUserRoleManage)), utils.PermUserRoleManage)
+	middleware.AllowAPIKey(admin.DELETE("/users/:id/role", adminController.RevokeRole, middleware.RequirePermission(utils.PermUserRoleManage)), utils.PermUserRoleManage)
+	middleware.AllowAPIKey(admin.GET("/security/2fa", adminController.GetTwoFactorPolicy, middleware.RequirePermission(utils.PermSecurityManage)), utils.PermSecurityManage)
+	middleware.AllowAPIKey(admin.PUT("/security/2fa", adminController.UpdateTwoFactorPolicy, middleware.RequirePermission(utils.PermSecurityManage)), utils.PermSecurityManage)
+	admin.POST("/users/:id/impersonate", adminController.ImpersonateUser, middleware.RequirePermission(utils.PermUserImpersonate))
 
 	recommendations := api.Group("/recommendations")
-	recommendations.POST("", recommendationController.CreateRecommendation)
-	recommendations.GET("/received", recommendationController.GetReceivedRecommendations)
+	middleware.AllowAPIKey(recommendations.POST("", recommendationController.CreateRecommendation), utils.ScopeRecommendations)
+	middleware.AllowAPIKey(recommendations.GET("/received", recommendationController.GetReceivedRecommendations), utils.ScopeRecommendations)
+	middleware.AllowAPIKey(recommendations.GET("/personalized", recommendationController.GetPersonalizedRecommendations), utils.ScopeRecommendations)
 }
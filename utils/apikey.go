@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/models"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	APIKeyPrefix      = "pls_"
+	ScopeAll          = "*"
+	apiKeyCacheTTL    = time.Minute
+	apiKeyIDLength    = 4
+	apiKeySecretBytes = 24
+)
+
+// Self-service scopes let a key act on its owner's own data. Unlike
+// permissions they are open to every role.
+const (
+	ScopeProfileRead     = "profile:read"
+	ScopeFavorites       = "favorites"
+	ScopeRecommendations = "recommendations"
+)
+
+var selfServiceScopes = []string{ScopeProfileRead, ScopeFavorites, ScopeRecommendations}
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+type APIKeyIdentity struct {
+	KeyID    primitive.ObjectID `json:"key_id"`
+	UserID   primitive.ObjectID `json:"user_id"`
+	Email    string             `json:"email"`
+	Role     string             `json:"role"`
+	Scopes   []string           `json:"scopes"`
+	MFA      bool               `json:"mfa"`
+	CachedAt int64              `json:"cached_at"`
+}
+
+func APIKeyCollection() *mongo.Collection {
+	return config.GetCollection(config.App.Mongo.Collections.APIKeys)
+}
+
+// GenerateAPIKey returns a new plaintext key and its short display prefix.
+func GenerateAPIKey() (string, string, error) {
+	id, err := GenerateToken(apiKeyIDLength)
+	if err != nil {
+		return "", "", err
+	}
+	secret, err := GenerateToken(apiKeySecretBytes)
+	if err != nil {
+		return "", "", err
+	}
+	prefix := APIKeyPrefix + id
+	return prefix + "_" + secret, prefix, nil
+}
+
+func APIKeyCacheKey(hash string) string {
+	return "apikey:" + hash
+}
+
+// ResolveAPIKey maps a plaintext key to the identity it acts as. Lookups are
+// cached briefly; a token revocation for the owner newer than the cache
+// entry forces a reload so deactivation and role changes apply at once.
+func ResolveAPIKey(ctx context.Context, key string) (*APIKeyIdentity, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	hash := HashToken(key)
+
+	var identity APIKeyIdentity
+	if hit, err := GetCached(ctx, APIKeyCacheKey(hash), &identity); hit && err == nil {
+		if !IsRevokedSince(ctx, identity.UserID, time.UnixMilli(identity.CachedAt)) {
+			return &identity, nil
+		}
+	}
+
+	var apiKey models.APIKey
+	err := APIKeyCollection().FindOne(ctx, bson.M{"hash": hash, "revokedAt": bson.M{"$exists": false}}).Decode(&apiKey)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := userCollection().FindOne(ctx, bson.M{"_id": apiKey.UserID}).Decode(&user); err != nil || !user.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	identity = APIKeyIdentity{
+		KeyID:    apiKey.ID,
+		UserID:   user.ID,
+		Email:    user.Email,
+		Role:     user.Role,
+		Scopes:   apiKey.Scopes,
+		MFA:      apiKey.MFA,
+		CachedAt: now.UnixMilli(),
+	}
+
+	ttl := apiKeyCacheTTL
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Sub(now) < ttl {
+		ttl = apiKey.ExpiresAt.Sub(now)
+	}
+	if err := SetCached(ctx, APIKeyCacheKey(hash), identity, ttl); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache result", "key", APIKeyCacheKey(hash), "error", err)
+	}
+	APIKeyCollection().UpdateOne(ctx, bson.M{"_id": apiKey.ID}, bson.M{"$set": bson.M{"lastUsedAt": now}})
+
+	return &identity, nil
+}
+
+func userCollection() *mongo.Collection {
+	return config.GetCollection(config.App.Mongo.Collections.User)
+}
+
+// IsGrantableScope reports whether a user with role may put scope on a key.
+func IsGrantableScope(role, scope string) bool {
+	return scope == ScopeAll || slices.Contains(selfServiceScopes, scope) || HasPermission(role, scope)
+}
+
+func HasScope(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if scope == ScopeAll || scope == permission {
+			return true
+		}
+	}
+	return false
+}
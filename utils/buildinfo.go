@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"PropertyListingSys/models"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Version and Commit are set at build time, e.g.
+// go build -ldflags "-X PropertyListingSys/utils.Version=1.4.0 -X PropertyListingSys/utils.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startedAt = time.Now()
+
+func GetBuildInfo() models.BuildInfo {
+	commit := Commit
+	if commit == "" {
+		commit = vcsRevision()
+	}
+	return models.BuildInfo{
+		Version:   Version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt,
+		Uptime:    time.Since(startedAt).Round(time.Second).String(),
+	}
+}
+
+// vcsRevision falls back to the revision the Go toolchain stamps into
+// binaries built from a git checkout.
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return "unknown"
+}
@@ -1,61 +1,105 @@
 package utils
 
 import (
+	"PropertyListingSys/config"
 	"errors"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Tokens carry iat in milliseconds so a token issued right after a
+// revocation is not mistaken for one issued before it.
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 type JWTClaims struct {
-	UserID primitive.ObjectID `json:"user_id"`
-	Email  string             `json:"email"`
-	Role   string             `json:"role"`
+	UserID         primitive.ObjectID  `json:"user_id"`
+	Email          string              `json:"email"`
+	Role           string              `json:"role"`
+	ImpersonatorID *primitive.ObjectID `json:"impersonator_id,omitempty"`
+	MFA            bool                `json:"mfa,omitempty"`
+	SessionID      string              `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID primitive.ObjectID, email, role string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", errors.New("JWT_SECRET not set")
-	}
+func JWTExpiry() time.Duration {
+	return time.Duration(config.App.JWT.ExpiryHours) * time.Hour
+}
+
+func GenerateJWT(userID primitive.ObjectID, email, role, sessionID string) (string, error) {
+	return signJWT(JWTClaims{
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
+	}, JWTExpiry())
+}
+
+func GenerateMFAJWT(userID primitive.ObjectID, email, role, sessionID string) (string, error) {
+	return signJWT(JWTClaims{
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		MFA:       true,
+		SessionID: sessionID,
+	}, JWTExpiry())
+}
 
-	expiryHours := os.Getenv("JWT_EXPIRY_HOURS")
-	if expiryHours == "" {
-		expiryHours = "24"
+func GenerateImpersonationJWT(userID primitive.ObjectID, email, role string, impersonatorID primitive.ObjectID, ttl time.Duration) (string, error) {
+	return signJWT(JWTClaims{
+		UserID:         userID,
+		Email:          email,
+		Role:           role,
+		ImpersonatorID: &impersonatorID,
+	}, ttl)
+}
+
+func JWTIssuer() string {
+	return config.App.JWT.Issuer
+}
+
+func JWTAudience() string {
+	return config.App.JWT.Audience
+}
+
+func signJWT(claims JWTClaims, ttl time.Duration) (string, error) {
+	if keyring == nil {
+		return "", errors.New("JWT keys not initialised")
 	}
 
-	hours, err := strconv.Atoi(expiryHours)
-	if err != nil {
-		hours = 24
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    JWTIssuer(),
+		Subject:   claims.UserID.Hex(),
+		Audience:  jwt.ClaimStrings{JWTAudience()},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
 	}
 
-	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(hours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	if keyring.signing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(keyring.legacySecret)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	token := jwt.NewWithClaims(keyring.signing.method, claims)
+	token.Header["kid"] = keyring.signing.kid
+	return token.SignedString(keyring.signing.private)
 }
 
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return nil, errors.New("JWT_SECRET not set")
+	if keyring == nil {
+		return nil, errors.New("JWT keys not initialised")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, keyring.verificationKey,
+		jwt.WithValidMethods(keyring.allowedAlgs),
+		jwt.WithIssuer(JWTIssuer()),
+		jwt.WithAudience(JWTAudience()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+type jwtKeyring struct {
+	signing       *jwtKey
+	verification  map[string]*jwtKey
+	allowedAlgs   []string
+	legacySecret  []byte
+	legacyEnabled bool
+}
+
+var keyring *jwtKeyring
+
+// InitJWTKeys loads signing and verification keys. Every *.pem file in
+// JWT_KEYS_DIR becomes a verification key whose kid is the file name; the
+// one named by JWT_SIGNING_KID must hold a private key and signs new tokens.
+// Without JWT_KEYS_DIR the service falls back to HS256 with JWT_SECRET.
+func InitJWTKeys() error {
+	cfg := config.App.JWT
+	ring := &jwtKeyring{verification: map[string]*jwtKey{}}
+
+	if cfg.Secret != "" {
+		ring.legacySecret = []byte(cfg.Secret)
+	}
+
+	dir := cfg.KeysDir
+	if dir == "" {
+		if ring.legacySecret == nil {
+			return errors.New("either JWT_KEYS_DIR or JWT_SECRET must be set")
+		}
+		// The operator removing HS256 from the allow-list must not be
+		// overridden by the fallback, so it has to be allowed explicitly.
+		if !slices.Contains(cfg.AllowedAlgs, jwt.SigningMethodHS256.Alg()) {
+			return errors.New("JWT_KEYS_DIR is unset, so tokens would be signed with HS256, but JWT_ALLOWED_ALGS does not include HS256")
+		}
+		slog.Warn("JWT_KEYS_DIR is unset; signing tokens with HS256 using JWT_SECRET")
+		ring.legacyEnabled = true
+		ring.allowedAlgs = []string{jwt.SigningMethodHS256.Alg()}
+		keyring = ring
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := loadJWTKey(file, kid)
+		if err != nil {
+			return fmt.Errorf("loading JWT key %s: %w", file, err)
+		}
+		ring.verification[kid] = key
+	}
+	if len(ring.verification) == 0 {
+		return fmt.Errorf("no *.pem keys found in %s", dir)
+	}
+
+	signingKID := cfg.SigningKID
+	signing, ok := ring.verification[signingKID]
+	if !ok {
+		return fmt.Errorf("JWT_SIGNING_KID %q does not match a key in %s", signingKID, dir)
+	}
+	if signing.private == nil {
+		return fmt.Errorf("JWT signing key %q has no private key", signingKID)
+	}
+	ring.signing = signing
+
+	ring.allowedAlgs = cfg.AllowedAlgs
+	if !slices.Contains(ring.allowedAlgs, signing.method.Alg()) {
+		return fmt.Errorf("JWT signing key %q uses %s, which is not in JWT_ALLOWED_ALGS", signingKID, signing.method.Alg())
+	}
+	for _, alg := range ring.allowedAlgs {
+		if alg == jwt.SigningMethodHS256.Alg() {
+			if ring.legacySecret == nil {
+				return errors.New("HS256 is allowed but JWT_SECRET is not set")
+			}
+			ring.legacyEnabled = true
+		}
+	}
+
+	keyring = ring
+	return nil
+}
+
+func loadJWTKey(file, kid string) (*jwtKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &jwtKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// verificationKey is the jwt.Keyfunc: it picks the key by kid and refuses
+// any token whose alg does not match that key.
+func (r *jwtKeyring) verificationKey(token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	if alg == jwt.SigningMethodHS256.Alg() {
+		if !r.legacyEnabled {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return r.legacySecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if key.method.Alg() != alg {
+		return nil, fmt.Errorf("key %q does not use %s", kid, alg)
+	}
+	return key.public, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes every asymmetric verification key. The HS256 secret is
+// never exposed.
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if keyring == nil {
+		return set
+	}
+
+	kids := make([]string, 0, len(keyring.verification))
+	for kid := range keyring.verification {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	for _, kid := range kids {
+		key := keyring.verification[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encode(pub.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encode(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+var MailClient Mailer
+
+func InitMailer() {
+	cfg := config.App.SMTP
+	if cfg.Host == "" {
+		MailClient = LogMailer{LogBodies: cfg.LogBodies}
+		return
+	}
+
+	MailClient = &SMTPMailer{
+		Addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Host:     cfg.Host,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+		Timeout:  time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+}
+
+// LogMailer stands in when SMTP is not configured. Bodies carry account
+// tokens, so they are only logged when LogBodies is set.
+type LogMailer struct {
+	LogBodies bool
+}
+
+func (m LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	logger := logging.FromContext(ctx)
+	logger.Info("Mail not sent: SMTP is not configured", "to", to, "subject", subject)
+	if m.LogBodies {
+		logger.Debug("Mail body", "to", to, "body", body)
+	}
+	return nil
+}
+
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("invalid mail header")
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+	return m.send(ctx, auth, to, []byte(msg.String()))
+}
+
+// send does what smtp.SendMail does, but dials with ctx and applies its
+// deadline to the connection so a slow or unreachable server cannot hold up
+// the request that triggered the email.
+func (m *SMTPMailer) send(ctx context.Context, auth smtp.Auth, to string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func SignupLink(email string) string {
+	return config.App.Links.SignupURL + "?email=" + url.QueryEscape(email)
+}
@@ -0,0 +1,69 @@
+package utils
+
+const (
+	RoleUser      = "user"
+	RoleAgent     = "agent"
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	PermPropertyCreate    = "property:create"
+	PermPropertyUpdateOwn = "property:update:own"
+	PermPropertyUpdateAny = "property:update:any"
+	PermPropertyDeleteOwn = "property:delete:own"
+	PermPropertyDeleteAny = "property:delete:any"
+	PermPropertyBatch     = "property:batch"
+	PermUserReadAll       = "user:read:all"
+	PermUserRoleManage    = "user:role:manage"
+	PermUserManage        = "user:manage"
+	PermUserImpersonate   = "user:impersonate"
+	PermSecurityManage    = "security:manage"
+)
+
+// listingPermissions let a role manage its own listings. Plain users browse,
+// favorite and recommend, but do not list properties.
+var listingPermissions = []string{
+	PermPropertyCreate,
+	PermPropertyUpdateOwn,
+	PermPropertyDeleteOwn,
+}
+
+var RolePermissions = map[string][]string{
+	RoleUser: {},
+	// Agents manage listings in bulk for many owners.
+	RoleAgent: append(append([]string{}, listingPermissions...),
+		PermPropertyBatch,
+	),
+	RoleOwner: listingPermissions,
+	RoleModerator: append(append([]string{}, listingPermissions...),
+		PermPropertyUpdateAny,
+		PermPropertyDeleteAny,
+		PermUserReadAll,
+	),
+	RoleAdmin: append(append([]string{}, listingPermissions...),
+		PermPropertyBatch,
+		PermPropertyUpdateAny,
+		PermPropertyDeleteAny,
+		PermUserReadAll,
+		PermUserRoleManage,
+		PermUserManage,
+		PermUserImpersonate,
+		PermSecurityManage,
+	),
+}
+
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+func HasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitRule allows Limit requests in any rolling Window.
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the oldest counted request leaves the window,
+	// freeing a slot.
+	Reset time.Duration
+}
+
+// slidingWindowScript keeps one sorted-set entry per allowed request, scored
+// by its time in microseconds. It uses the Redis server clock so every
+// instance sees the same window; replicate_commands lets Redis versions
+// before 5 accept writes after TIME. Rejected requests are not recorded, so a
+// client that keeps retrying gets through as soon as a slot frees up.
+//
+// KEYS[1] key; ARGV[1] limit; ARGV[2] window in microseconds; ARGV[3] a
+// unique member for this request.
+// Returns {allowed, remaining, microseconds until a slot frees up}.
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[3])
+  count = count + 1
+  allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+
+local reset = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+  reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// AllowRequest counts one request against key under rule. The caller decides
+// what to do on error; the rate limit middleware fails open.
+func AllowRequest(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	member, err := GenerateToken(8)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	values, err := slidingWindowScript.Run(ctx, RedisClient, []string{key},
+		rule.Limit, rule.Window.Microseconds(), member).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:   values[0] == 1,
+		Remaining: int(max(values[1], 0)),
+		Reset:     time.Duration(values[2]) * time.Microsecond,
+	}, nil
+}
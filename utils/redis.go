@@ -1,43 +1,72 @@
 package utils
 
 import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"PropertyListingSys/metrics"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
-	"os"
+	"log/slog"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 var RedisClient *redis.Client
 
 func InitRedis() {
-	addr := os.Getenv("REDIS_ADDR")
-	if addr == "" {
-		addr = "localhost:6379"
+	cfg := config.App.Redis
+	timeout := time.Duration(cfg.TimeoutMillis) * time.Millisecond
+	RedisClient = redis.NewClient(&redis.Options{
+		Addr:                  cfg.Addr,
+		Password:              cfg.Password,
+		DB:                    cfg.DB,
+		DialTimeout:           timeout,
+		ReadTimeout:           timeout,
+		WriteTimeout:          timeout,
+		PoolTimeout:           timeout,
+		ContextTimeoutEnabled: true,
+	})
+	// Commands are traced without their arguments, which can hold tokens
+	// and personal data.
+	if err := redisotel.InstrumentTracing(RedisClient, redisotel.WithDBStatement(false)); err != nil {
+		slog.Warn("Failed to instrument Redis tracing", "error", err)
 	}
+}
 
-	password := os.Getenv("REDIS_PASSWORD")
+// CloseRedis closes the Redis client and its connection pool.
+func CloseRedis() error {
+	if RedisClient == nil {
+		return nil
+	}
+	return RedisClient.Close()
+}
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       0,
-	})
+// cacheContext gives a cache call the short cache budget so a slow Redis
+// costs a request at most that long before it falls back to MongoDB.
+func cacheContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(config.App.Redis.CacheTimeoutMillis)*time.Millisecond)
 }
 
 func GetCached(ctx context.Context, key string, dest interface{}) (bool, error) {
+	ctx, cancel := cacheContext(ctx)
+	defer cancel()
+
 	data, err := RedisClient.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.ObserveCacheLookup(key, "miss")
 		return false, nil
 	}
 	if err != nil {
+		metrics.ObserveCacheLookup(key, "error")
 		return false, err
 	}
+	metrics.ObserveCacheLookup(key, "hit")
 	return true, json.Unmarshal([]byte(data), dest)
 }
 
@@ -46,9 +75,44 @@ func SetCached(ctx context.Context, key string, value interface{}, ttl time.Dura
 	if err != nil {
 		return err
 	}
+	ctx, cancel := cacheContext(ctx)
+	defer cancel()
 	return RedisClient.Set(ctx, key, data, ttl).Err()
 }
 
+// DeleteCachedPattern deletes every key matching a glob pattern such as
+// "properties:*". DEL does not expand patterns, so the keys are found with
+// SCAN, which does not block Redis the way KEYS would.
+func DeleteCachedPattern(ctx context.Context, pattern string) error {
+	iter := RedisClient.Scan(ctx, 0, pattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 100 {
+			if err := RedisClient.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return RedisClient.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+// InvalidatePropertyLists drops the cached ListProperties pages, which may
+// contain any property. Call it after properties are created, changed or
+// deleted.
+func InvalidatePropertyLists(ctx context.Context) {
+	if err := DeleteCachedPattern(ctx, "properties:*"); err != nil {
+		logging.FromContext(ctx).Warn("Failed to invalidate property list caches", "error", err)
+	}
+}
+
 func GenerateQueryCacheKey(prefix string, queryParams map[string]string) string {
 	keys := make([]string, 0, len(queryParams))
 	for k := range queryParams {
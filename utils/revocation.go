@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"PropertyListingSys/logging"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func revokedBeforeKey(userID primitive.ObjectID) string {
+	return "user:revoked_before:" + userID.Hex()
+}
+
+// RevokeUserTokens invalidates every token issued to the user before now.
+// The time is kept on the user document and in Redis; the Redis marker only
+// has to outlive the longest-lived token, so it expires with it. Sessions are
+// marked revoked too so they drop out of the user's device list.
+func RevokeUserTokens(ctx context.Context, userID primitive.ObjectID) error {
+	now := time.Now().Truncate(time.Millisecond)
+	if _, err := userCollection().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"tokens_revoked_at": now}}); err != nil {
+		return err
+	}
+	if err := RedisClient.Set(ctx, revokedBeforeKey(userID), strconv.FormatInt(now.UnixMilli(), 10), JWTExpiry()).Err(); err != nil {
+		return err
+	}
+	_, err := RevokeSessions(ctx, userID, bson.M{})
+	return err
+}
+
+func IsTokenRevoked(ctx context.Context, claims *JWTClaims) bool {
+	if claims.IssuedAt == nil {
+		return false
+	}
+	return IsRevokedSince(ctx, claims.UserID, claims.IssuedAt.Time)
+}
+
+// IsRevokedSince reports whether the user's credentials were revoked after
+// issuedAt. If Redis cannot answer, the user document decides; if neither
+// can, the credentials are treated as revoked.
+func IsRevokedSince(ctx context.Context, userID primitive.ObjectID, issuedAt time.Time) bool {
+	value, err := RedisClient.Get(ctx, revokedBeforeKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("Revocation check fell back to MongoDB", "error", err)
+		return isRevokedInUserDocument(ctx, userID, issuedAt)
+	}
+	revokedBefore, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return true
+	}
+	return issuedAt.UnixMilli() < revokedBefore
+}
+
+func isRevokedInUserDocument(ctx context.Context, userID primitive.ObjectID, issuedAt time.Time) bool {
+	var user struct {
+		TokensRevokedAt *time.Time `bson:"tokens_revoked_at"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"tokens_revoked_at": 1})
+	if err := userCollection().FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user); err != nil {
+		logging.FromContext(ctx).Error("Revocation check failed; rejecting credentials", "error", err)
+		return true
+	}
+	return user.TokensRevokedAt != nil && issuedAt.UnixMilli() < user.TokensRevokedAt.UnixMilli()
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/models"
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	sessionCacheTTL      = time.Minute
+	sessionTouchInterval = time.Minute
+	sessionStatusActive  = "active"
+	sessionStatusRevoked = "revoked"
+)
+
+func SessionCollection() *mongo.Collection {
+	return config.GetCollection(config.App.Mongo.Collections.Sessions)
+}
+
+func SessionCacheKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// CreateSession records a new login. The session lives as long as the token
+// issued for it.
+func CreateSession(ctx context.Context, userID primitive.ObjectID, userAgent, ip string, mfa bool) (*models.Session, error) {
+	now := time.Now()
+	session := &models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Device:     DeviceFromUserAgent(userAgent),
+		UserAgent:  userAgent,
+		IP:         ip,
+		MFA:        mfa,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(JWTExpiry()),
+	}
+	if _, err := SessionCollection().InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+	RedisClient.Set(ctx, SessionCacheKey(session.ID.Hex()), sessionStatusActive, sessionCacheTTL)
+	return session, nil
+}
+
+// IsSessionActive reports whether the session has not been revoked or
+// expired. The answer is cached briefly; revocation overwrites the cache so
+// it takes effect immediately. An error means the session could not be
+// checked, and the caller must not treat it as active.
+func IsSessionActive(ctx context.Context, sessionID string) (bool, error) {
+	cacheKey := SessionCacheKey(sessionID)
+	cacheCtx, cancel := cacheContext(ctx)
+	status, err := RedisClient.Get(cacheCtx, cacheKey).Result()
+	cancel()
+	if err == nil {
+		return status == sessionStatusActive, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false, nil
+	}
+
+	var session models.Session
+	err = SessionCollection().FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	active := err == nil && session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+
+	status = sessionStatusRevoked
+	if active {
+		status = sessionStatusActive
+	}
+	RedisClient.Set(ctx, cacheKey, status, sessionCacheTTL)
+	return active, nil
+}
+
+// TouchSession updates last-seen details, at most once per interval per
+// session to keep writes off the request path.
+func TouchSession(ctx context.Context, sessionID, ip string) {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return
+	}
+	first, err := RedisClient.SetNX(ctx, SessionCacheKey(sessionID)+":seen", "1", sessionTouchInterval).Result()
+	if err != nil || !first {
+		return
+	}
+	SessionCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"lastSeenAt": time.Now(), "lastSeenIp": ip},
+	})
+}
+
+// RevokeSessions marks the matching active sessions of a user revoked and
+// returns how many were revoked.
+func RevokeSessions(ctx context.Context, userID primitive.ObjectID, filter bson.M) (int, error) {
+	filter["userId"] = userID
+	filter["revokedAt"] = bson.M{"$exists": false}
+
+	ids, err := SessionCollection().Distinct(ctx, "_id", filter)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	_, err = SessionCollection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			RedisClient.Set(ctx, SessionCacheKey(oid.Hex()), sessionStatusRevoked, sessionCacheTTL)
+		}
+	}
+	return len(ids), nil
+}
+
+// DeviceFromUserAgent gives a short human-readable label such as
+// "Chrome on Windows".
+func DeviceFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	var platform string
+	switch {
+	case strings.Contains(ua, "iphone"):
+		platform = "iPhone"
+	case strings.Contains(ua, "ipad"):
+		platform = "iPad"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"), strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	var client string
+	switch {
+	case strings.Contains(ua, "edg/"):
+		client = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		client = "Firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		client = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		client = "Safari"
+	case strings.Contains(ua, "curl/"):
+		client = "curl"
+	}
+
+	switch {
+	case client != "" && platform != "":
+		return client + " on " + platform
+	case client != "":
+		return client
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
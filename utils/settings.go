@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/models"
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	securitySettingsID       = "security"
+	securitySettingsCacheTTL = 30 * time.Second
+)
+
+var (
+	securitySettingsMu       sync.Mutex
+	securitySettingsCache    models.SecuritySettings
+	securitySettingsLoadedAt time.Time
+	securitySettingsLoads    singleflight.Group
+)
+
+func settingsCollection() *mongo.Collection {
+	return config.GetCollection(config.App.Mongo.Collections.Settings)
+}
+
+// GetSecuritySettings returns the security settings, held in memory for a
+// short time because they are consulted on every permission check. When the
+// copy expires one caller reloads it while concurrent callers wait for that
+// load; the lock is never held across the database call.
+func GetSecuritySettings(ctx context.Context) (models.SecuritySettings, error) {
+	securitySettingsMu.Lock()
+	settings, loadedAt := securitySettingsCache, securitySettingsLoadedAt
+	securitySettingsMu.Unlock()
+	if time.Since(loadedAt) < securitySettingsCacheTTL {
+		return settings, nil
+	}
+
+	loaded, err, _ := securitySettingsLoads.Do(securitySettingsID, func() (interface{}, error) {
+		settings := models.SecuritySettings{ID: securitySettingsID}
+		err := settingsCollection().FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&settings)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+
+		securitySettingsMu.Lock()
+		securitySettingsCache = settings
+		securitySettingsLoadedAt = time.Now()
+		securitySettingsMu.Unlock()
+		return settings, nil
+	})
+	if err != nil {
+		return models.SecuritySettings{}, err
+	}
+	return loaded.(models.SecuritySettings), nil
+}
+
+func SaveSecuritySettings(ctx context.Context, settings models.SecuritySettings) error {
+	settings.ID = securitySettingsID
+	settings.UpdatedAt = time.Now()
+	_, err := settingsCollection().ReplaceOne(ctx, bson.M{"_id": securitySettingsID}, settings, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	securitySettingsMu.Lock()
+	securitySettingsCache = settings
+	securitySettingsLoadedAt = time.Now()
+	securitySettingsMu.Unlock()
+	return nil
+}
+
+// TwoFactorRequired reports whether role must sign in with a second factor.
+// Callers must refuse the request when the settings cannot be loaded rather
+// than assume 2FA is not required.
+func TwoFactorRequired(ctx context.Context, role string) (bool, error) {
+	settings, err := GetSecuritySettings(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range settings.TwoFactorRequiredRoles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
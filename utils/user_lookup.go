@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"PropertyListingSys/config"
+	"PropertyListingSys/logging"
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.]{2,29}$`)
+
+// NormalizeHandle lowercases a handle and strips a leading "@".
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+func IsValidHandle(handle string) bool {
+	return handlePattern.MatchString(handle)
+}
+
+// EnsureUserIndexes creates the unique index that keeps handles unique.
+// Users without a handle have no handle field, so the index is sparse.
+func EnsureUserIndexes(ctx context.Context) error {
+	_, err := userCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "handle", Value: 1}},
+		Options: options.Index().SetName("handle_unique").SetUnique(true).SetSparse(true),
+	})
+	return err
+}
+
+// NormalizeStoredEmails lowercases and trims emails saved before addresses
+// were normalized on the way in. An address that would then clash with
+// another account's is left alone and logged, since merging the two accounts
+// needs a person to decide.
+func NormalizeStoredEmails(ctx context.Context) error {
+	users := userCollection()
+	cursor, err := users.Find(ctx,
+		bson.M{"email": bson.M{"$regex": `[A-Z]|^\s|\s$`}},
+		options.Find().SetProjection(bson.M{"email": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Email string             `bson:"email"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		email := NormalizeEmail(user.Email)
+
+		err := users.FindOne(ctx, bson.M{"email": email, "_id": bson.M{"$ne": user.ID}}).Err()
+		if err == nil {
+			logging.FromContext(ctx).Warn("Email differs from another account's only by case; left unchanged", "user_id", user.ID.Hex())
+			continue
+		}
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"email": email}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// GenerateInviteCode returns a shareable code that resolves to its owner
+// without revealing their email address.
+func GenerateInviteCode() (string, error) {
+	return GenerateToken(6)
+}
+
+// UserLookupRetryAfter counts one user lookup (by email, handle or invite
+// code) against the caller's quota of USER_LOOKUP_LIMIT per
+// USER_LOOKUP_WINDOW_MINUTES. It returns how long to wait once the quota is
+// spent, or zero if the lookup may proceed. Redis failures fail open.
+func UserLookupRetryAfter(ctx context.Context, userID primitive.ObjectID) time.Duration {
+	limit := config.App.Users.LookupLimit
+	window := time.Duration(config.App.Users.LookupWindowMinutes) * time.Minute
+
+	key := "user_lookup:" + userID.Hex()
+	count, err := RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0
+	}
+	if count == 1 {
+		RedisClient.Expire(ctx, key, window)
+	}
+	if count <= int64(limit) {
+		return 0
+	}
+
+	ttl, err := RedisClient.PTTL(ctx, key).Result()
+	if err != nil || ttl <= 0 {
+		RedisClient.Expire(ctx, key, window)
+		return window
+	}
+	return ttl
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/mail"
 	"strconv"
 	"strings"
 )
@@ -16,3 +17,14 @@ func IsValidExternalID(id string) bool {
 	}
 	return true
 }
+
+// NormalizeEmail trims and lowercases an address. Emails are stored this way
+// so lookups match however the user typed them.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func IsValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}